@@ -0,0 +1,223 @@
+package lvslb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// An entry of backends.ip is an IP, a CIDR, a range <start>-<end> or a hostname.
+// CIDRs and ranges are expanded in all their addresses
+// (without network and broadcast addresses for IPv4 CIDRs shorter than /31),
+// hostnames are resolved at plan time and the result saved in resolved_hostnames.
+
+const defaultMaxBackendRangeSize = 256
+
+// expandBackendIP returns IPs of entry.
+// resolved contains IPs of hostnames (see resolveHostname).
+func expandBackendIP(entry string, maxSize int, resolved map[string][]string) ([]string, error) {
+	if net.ParseIP(entry) != nil {
+		return []string{entry}, nil
+	}
+	if strings.Contains(entry, "/") {
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("[ERROR] %v isn't a valid CIDR", entry)
+		}
+		ones, bits := ipNet.Mask.Size()
+		if bits-ones > 30 || 1<<(bits-ones) > maxSize {
+			return nil, fmt.Errorf("[ERROR] CIDR %v contains more than %d addresses (max_backend_range_size)",
+				entry, maxSize)
+		}
+		start := make(net.IP, len(ipNet.IP))
+		copy(start, ipNet.IP)
+		end := make(net.IP, len(ipNet.IP))
+		for i := range ipNet.IP {
+			end[i] = ipNet.IP[i] | ^ipNet.Mask[i]
+		}
+		// skip network and broadcast addresses of IPv4 subnet
+		// (at least 2 bits of host in last byte so no carry)
+		if start.To4() != nil && bits-ones > 1 {
+			start[len(start)-1]++
+			end[len(end)-1]--
+		}
+
+		return ipsBetween(start, end), nil
+	}
+	if start, end, ok := parseIPRange(entry); ok {
+		if (start.To4() == nil) != (end.To4() == nil) {
+			return nil, fmt.Errorf("[ERROR] start and end of range %v don't have same IP family", entry)
+		}
+		size := new(big.Int).Sub(new(big.Int).SetBytes(end.To16()), new(big.Int).SetBytes(start.To16()))
+		if size.Sign() < 0 {
+			return nil, fmt.Errorf("[ERROR] start of range %v is greater than end", entry)
+		}
+		if size.Cmp(big.NewInt(int64(maxSize-one))) > 0 {
+			return nil, fmt.Errorf("[ERROR] range %v contains more than %d addresses (max_backend_range_size)",
+				entry, maxSize)
+		}
+
+		return ipsBetween(start, end), nil
+	}
+	ips, ok := resolved[entry]
+	if !ok {
+		return nil, fmt.Errorf("[ERROR] hostname %v isn't resolved", entry)
+	}
+
+	return ips, nil
+}
+
+// parseIPRange splits entry in format <start>-<end> (hostnames can contain '-' but not be IPs on both sides).
+func parseIPRange(entry string) (net.IP, net.IP, bool) {
+	rangeSplit := strings.Split(entry, "-")
+	if len(rangeSplit) != 2 {
+		return nil, nil, false
+	}
+	start := net.ParseIP(strings.TrimSpace(rangeSplit[0]))
+	end := net.ParseIP(strings.TrimSpace(rangeSplit[1]))
+	if start == nil || end == nil {
+		return nil, nil, false
+	}
+
+	return start, end, true
+}
+
+// isHostname returns true if entry of backends.ip isn't an IP, a CIDR or a range.
+func isHostname(entry string) bool {
+	if net.ParseIP(entry) != nil || strings.Contains(entry, "/") {
+		return false
+	}
+	_, _, isRange := parseIPRange(entry)
+
+	return !isRange
+}
+
+// ipsBetween returns all IPs from start to end (included).
+func ipsBetween(start, end net.IP) []string {
+	if start.To4() != nil {
+		start = start.To4()
+		end = end.To4()
+	}
+	ip := make(net.IP, len(start))
+	copy(ip, start)
+	ips := make([]string, 0)
+	for {
+		ips = append(ips, ip.String())
+		if bytes.Equal(ip, end) {
+			return ips
+		}
+		for i := len(ip) - 1; i >= 0; i-- {
+			ip[i]++
+			if ip[i] != 0 {
+				break
+			}
+		}
+	}
+}
+
+// resolveHostname returns sorted IPs of hostname with same family as vip (if vip is known).
+func resolveHostname(ctx context.Context, hostname, vip string) ([]string, error) {
+	network := "ip"
+	if vipIP := net.ParseIP(vip); vipIP != nil {
+		if vipIP.To4() != nil {
+			network = "ip4"
+		} else {
+			network = "ip6"
+		}
+	}
+	ipsResolved, err := net.DefaultResolver.LookupIP(ctx, network, hostname)
+	if err != nil {
+		return nil, fmt.Errorf("[ERROR] can't resolve hostname %v: %w", hostname, err)
+	}
+	ips := make([]string, 0, len(ipsResolved))
+	seen := make(map[string]bool)
+	for _, v := range ipsResolved {
+		if ip := v.String(); !seen[ip] {
+			seen[ip] = true
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("[ERROR] hostname %v doesn't have address for virtual server %v", hostname, vip)
+	}
+	sort.Strings(ips)
+
+	return ips, nil
+}
+
+// resolveBackendHostnames sets resolved_hostnames with IPs of hostnames in backends blocks.
+// IPs already resolved at plan time are kept, others (hostnames unknown at plan time) are resolved.
+func resolveBackendHostnames(ctx context.Context, d *schema.ResourceData) error {
+	planned := readResolvedHostnames(d)
+	resolved := make(map[string][]string)
+	for _, dataBackend := range d.Get("backends").([]interface{}) {
+		if dataBackend == nil {
+			continue
+		}
+		for _, backendIP := range dataBackend.(map[string]interface{})["ip"].([]interface{}) {
+			entry, ok := backendIP.(string)
+			if !ok || !isHostname(entry) {
+				continue
+			}
+			if _, ok := resolved[entry]; ok {
+				continue
+			}
+			if ips, ok := planned[entry]; ok {
+				resolved[entry] = ips
+
+				continue
+			}
+			ips, err := resolveHostname(ctx, entry, d.Get("ip").(string))
+			if err != nil {
+				return err
+			}
+			resolved[entry] = ips
+		}
+	}
+	if tfErr := d.Set("resolved_hostnames", flattenResolvedHostnames(resolved)); tfErr != nil {
+		panic(tfErr)
+	}
+
+	return nil
+}
+
+func readResolvedHostnames(d *schema.ResourceData) map[string][]string {
+	resolved := make(map[string][]string)
+	for _, v := range d.Get("resolved_hostnames").([]interface{}) {
+		if v == nil {
+			continue
+		}
+		resolvedHostname := v.(map[string]interface{})
+		ips := make([]string, 0)
+		for _, ip := range resolvedHostname["ips"].([]interface{}) {
+			ips = append(ips, ip.(string))
+		}
+		resolved[resolvedHostname["hostname"].(string)] = ips
+	}
+
+	return resolved
+}
+
+// flattenResolvedHostnames returns resolved_hostnames sorted by hostname.
+func flattenResolvedHostnames(resolved map[string][]string) []map[string]interface{} {
+	hostnames := make([]string, 0, len(resolved))
+	for k := range resolved {
+		hostnames = append(hostnames, k)
+	}
+	sort.Strings(hostnames)
+	resolvedHostnames := make([]map[string]interface{}, 0, len(hostnames))
+	for _, v := range hostnames {
+		resolvedHostnames = append(resolvedHostnames, map[string]interface{}{
+			"hostname": v,
+			"ips":      resolved[v],
+		})
+	}
+
+	return resolvedHostnames
+}
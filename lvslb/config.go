@@ -1,78 +1,151 @@
 package lvslb
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
 	"strings"
+	"time"
 
-	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
 )
 
+// logSubsystemAPI is the name of tflog subsystem for requests to API.
+const logSubsystemAPI = "api"
+
 // Config provider.
 type Config struct {
-	https        bool
-	insecure     bool
-	vaultEnable  bool
-	firewallPort int
-	firewallIP   string
-	logname      string
-	login        string
-	password     string
-	vaultPath    string
-	vaultKey     string
+	https               bool
+	insecure            bool
+	firewallPort        int
+	maxParallelRequests int
+	maxRetries          int
+	retryWaitMin        int
+	retryWaitMax        int
+	timeouts            lvslbapi.Timeouts
+	caCertFile          string
+	caCertPEM           string
+	clientCert          string
+	clientKey           string
+	tlsServerName       string
+	minTLSVersion       string
+	endpoints           []string
+	logname             string
+	login               string
+	password            string
+	vault               *vaultConfig
+	requestTags         map[string]string
+	logRequestBodies    bool
 }
 
 // Client configures with Config.
-func (c *Config) Client() (*Client, error) {
-	var client *Client
-	if !c.vaultEnable {
-		client = NewClient(c.firewallIP, c.firewallPort, c.https, c.insecure, c.logname, c.login, c.password)
-	} else {
-		login, password := getLoginVault(c.vaultPath, c.firewallIP, c.vaultKey)
-		client = NewClient(c.firewallIP, c.firewallPort, c.https, c.insecure, c.logname, login, password)
+func (c *Config) Client(ctx context.Context) (*lvslbapi.Client, error) {
+	login, password := c.login, c.password
+	logSecrets := make([]string, 0)
+	if c.vault != nil {
+		var err error
+		login, password, err = c.vault.getLogin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		logSecrets = append(logSecrets, login, c.vault.token, c.vault.approleSecretID, c.vault.jwt)
+	}
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	return client, nil
+	return lvslbapi.New(c.endpoints[0],
+		lvslbapi.WithPeers(c.endpoints[1:]...),
+		lvslbapi.WithPort(c.firewallPort),
+		lvslbapi.WithHTTPS(c.https),
+		lvslbapi.WithLogname(c.logname),
+		lvslbapi.WithBasicAuth(login, password),
+		lvslbapi.WithRequestTags(c.requestTags),
+		lvslbapi.WithLogger(tflogAPILogger{}),
+		lvslbapi.WithLogRequestBodies(c.logRequestBodies),
+		lvslbapi.WithLogSecrets(append(logSecrets, c.clientKey)...),
+		lvslbapi.WithMaxParallelRequests(c.maxParallelRequests),
+		lvslbapi.WithRetry(c.maxRetries,
+			time.Duration(c.retryWaitMin)*time.Second, time.Duration(c.retryWaitMax)*time.Second),
+		lvslbapi.WithTLSConfig(tlsConfig),
+		lvslbapi.WithTimeouts(c.timeouts),
+	)
 }
 
-func getLoginVault(path string, firewallIP string, key string) (string, string) {
-	login := ""
-	password := ""
-	client, err := vaultapi.NewClient(vaultapi.DefaultConfig())
-	if err != nil {
-		return "", ""
-	}
+// tflogAPILogger sends logs of lvslbapi.Client to tflog subsystem for API.
+type tflogAPILogger struct{}
 
-	c := client.Logical()
-	if key != "" {
-		secret, err := c.Read(strings.Join([]string{"/secret/", path, "/", key}, ""))
-		if err != nil {
-			return "", ""
+func (tflogAPILogger) Debug(ctx context.Context, msg string, fields map[string]interface{}) {
+	tflog.SubsystemDebug(tflog.NewSubsystem(ctx, logSubsystemAPI), logSubsystemAPI, msg, fields)
+}
+
+func (tflogAPILogger) Warn(ctx context.Context, msg string, fields map[string]interface{}) {
+	tflog.SubsystemWarn(tflog.NewSubsystem(ctx, logSubsystemAPI), logSubsystemAPI, msg, fields)
+}
+
+// tlsConfig returns TLS configuration for connection to API.
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.insecure, //nolint:gosec // insecure provider argument, disabled by default
+		ServerName:         c.tlsServerName,
+	}
+	if c.minTLSVersion != "" {
+		version, ok := tlsVersions[c.minTLSVersion]
+		if !ok {
+			return nil, fmt.Errorf("[ERROR] unknown min_tls_version %v", c.minTLSVersion)
 		}
-		if secret != nil {
-			for key, value := range secret.Data {
-				if key == "login" {
-					login = value.(string)
-				}
-				if key == "password" {
-					password = value.(string)
-				}
+		tlsConfig.MinVersion = version
+	}
+	if c.caCertFile != "" || c.caCertPEM != "" {
+		caCert := []byte(c.caCertPEM)
+		if c.caCertFile != "" {
+			var err error
+			caCert, err = ioutil.ReadFile(c.caCertFile)
+			if err != nil {
+				return nil, fmt.Errorf("[ERROR] read ca_cert_file: %w", err)
 			}
 		}
-	} else {
-		secret, err := c.Read(strings.Join([]string{"/secret/", path, "/", firewallIP}, ""))
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("[ERROR] no valid PEM certificate found in CA bundle")
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+	if c.clientCert != "" || c.clientKey != "" {
+		clientCert, err := readPEMOrFile(c.clientCert)
 		if err != nil {
-			return "", ""
+			return nil, fmt.Errorf("[ERROR] read client_cert: %w", err)
 		}
-		if secret != nil {
-			for key, value := range secret.Data {
-				if key == "login" {
-					login = value.(string)
-				}
-				if key == "password" {
-					password = value.(string)
-				}
-			}
+		clientKey, err := readPEMOrFile(c.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("[ERROR] read client_key: %w", err)
+		}
+		cert, err := tls.X509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("[ERROR] load client certificate: %w", err)
 		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// readPEMOrFile returns value if it's PEM content, else content of file at path value.
+func readPEMOrFile(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
 	}
 
-	return login, password
+	return ioutil.ReadFile(value)
 }
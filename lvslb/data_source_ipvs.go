@@ -0,0 +1,205 @@
+package lvslb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+)
+
+func dataSourceIpvs() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIpvsRead,
+
+		Schema: map[string]*schema.Schema{
+			"ip": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.IsIPAddress,
+			},
+			"port": {
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validation.IntBetween(0, maxInternetPort),
+			},
+			"protocol": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "TCP",
+				ValidateFunc: validation.StringInSlice([]string{"TCP", "UDP", "SCTP"}, true),
+			},
+			"type": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"algo": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"persistence_timeout": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"timer_check": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"sorry_server_ip": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"sorry_server_port": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"virtualhost": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"monitoring_period": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"backends": computedIpvsBackendsSchema(),
+		},
+	}
+}
+
+func dataSourceIpvsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*lvslbapi.Client)
+	key := lvslbapi.VirtualServerKey{
+		IP:       d.Get("ip").(string),
+		Protocol: lvslbapi.Protocol(strings.ToUpper(d.Get("protocol").(string))),
+		Port:     d.Get("port").(int),
+	}
+	IpvsRead, err := client.GetVirtualServer(ctx, key)
+	if err != nil {
+		if errors.Is(err, lvslbapi.ErrNotFound) {
+			return diagFromErr(fmt.Errorf("[ERROR] virtual server %v %v:%v not found (API returned 404)",
+				key.Protocol, key.IP, key.Port))
+		}
+
+		return diagFromErr(err)
+	}
+	fillDataSourceIpvs(d, IpvsRead)
+	d.SetId(key.String())
+
+	return nil
+}
+
+func fillDataSourceIpvs(d *schema.ResourceData, ipvsRead *lvslbapi.VirtualServer) {
+	if tfErr := d.Set("type", string(ipvsRead.LbKind)); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("algo", string(ipvsRead.LbAlgo)); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("persistence_timeout", ipvsRead.PersistenceTimeout); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("timer_check", ipvsRead.DelayLoop); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("sorry_server_ip", ipvsRead.SorryIP); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("sorry_server_port", ipvsRead.SorryPort); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("virtualhost", ipvsRead.Virtualhost); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("monitoring_period", ipvsRead.MonPeriod); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("backends", flattenIpvsBackends(ipvsRead.Backends)); tfErr != nil {
+		panic(tfErr)
+	}
+}
+
+func computedIpvsBackendsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Computed: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"ip": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"port": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"weight": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"check_type": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"check_port": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"check_timeout": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"nb_get_retry": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"delay_before_retry": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"check_url": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"check_digest": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"check_status_code": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"misc_path": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+			},
+		},
+	}
+}
+
+// flattenIpvsBackends returns one element per backend returned by API.
+func flattenIpvsBackends(ipvsBackendsRead []lvslbapi.Backend) []map[string]interface{} {
+	backends := make([]map[string]interface{}, 0, len(ipvsBackendsRead))
+	for _, v := range ipvsBackendsRead {
+		backends = append(backends, map[string]interface{}{
+			"ip":                 v.IP,
+			"port":               v.Port,
+			"weight":             v.Weight,
+			"check_type":         string(v.CheckType),
+			"check_port":         v.CheckPort,
+			"check_timeout":      v.CheckTimeout,
+			"nb_get_retry":       v.NbGetRetry,
+			"delay_before_retry": v.DelayBeforeRetry,
+			"check_url":          v.URLPath,
+			"check_digest":       v.URLDigest,
+			"check_status_code":  v.URLStatusCode,
+			"misc_path":          v.MiscPath,
+		})
+	}
+
+	return backends
+}
@@ -0,0 +1,120 @@
+package lvslb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+)
+
+func dataSourceIpvsBackendStatus() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIpvsBackendStatusRead,
+
+		Schema: map[string]*schema.Schema{
+			"ip": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.IsIPAddress,
+			},
+			"port": {
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validation.IntBetween(0, maxInternetPort),
+			},
+			"protocol": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "TCP",
+				ValidateFunc: validation.StringInSlice([]string{"TCP", "UDP", "SCTP"}, true),
+			},
+			"alive_count": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"backends": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ip": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"port": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"alive": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"weight": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"active_connections": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"inactive_connections": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceIpvsBackendStatusRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*lvslbapi.Client)
+	key := lvslbapi.VirtualServerKey{
+		IP:       d.Get("ip").(string),
+		Protocol: lvslbapi.Protocol(strings.ToUpper(d.Get("protocol").(string))),
+		Port:     d.Get("port").(int),
+	}
+	IpvsStatus, err := client.GetVirtualServerStatus(ctx, key)
+	if err != nil {
+		if errors.Is(err, lvslbapi.ErrNotFound) {
+			return diagFromErr(fmt.Errorf("[ERROR] virtual server %v %v:%v not found (API returned 404)",
+				key.Protocol, key.IP, key.Port))
+		}
+		if errors.Is(err, lvslbapi.ErrStatusNotSupported) {
+			return diagFromErr(fmt.Errorf("[ERROR] %w, lvslb-api needs /status_ipvs/ endpoint "+
+				"to read state of backends", err))
+		}
+
+		return diagFromErr(err)
+	}
+	aliveCount := 0
+	backends := make([]map[string]interface{}, 0, len(IpvsStatus.Backends))
+	for _, v := range IpvsStatus.Backends {
+		if v.Alive {
+			aliveCount++
+		}
+		backends = append(backends, map[string]interface{}{
+			"ip":                   v.IP,
+			"port":                 v.Port,
+			"alive":                v.Alive,
+			"weight":               v.Weight,
+			"active_connections":   v.ActiveConn,
+			"inactive_connections": v.InactiveConn,
+		})
+	}
+	if tfErr := d.Set("alive_count", aliveCount); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("backends", backends); tfErr != nil {
+		panic(tfErr)
+	}
+	d.SetId(key.String())
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package lvslb_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslb"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi/lvslbapitest"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceIpvsBackendStatus_read(t *testing.T) {
+	server := lvslbapitest.NewServer("", "")
+	defer server.Close()
+	client, err := lvslbapi.New(server.URL, lvslbapi.WithRetry(0, 0, 0))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	server.SetVirtualServer(lvslbapi.VirtualServer{
+		IP: "203.0.113.1", Port: 80, Protocol: lvslbapi.ProtocolTCP,
+		Backends: []lvslbapi.Backend{
+			{IP: "192.0.2.1", Port: 80, Weight: 1},
+			{IP: "192.0.2.2", Port: 80, Weight: 2},
+		},
+	})
+	dataSource := lvslb.Provider().DataSourcesMap["lvslb_ipvs_backend_status"]
+	read := func(ip string) (*schema.ResourceData, string) {
+		d := schema.TestResourceDataRaw(t, dataSource.Schema, map[string]interface{}{"ip": ip, "port": 80})
+		diags := dataSource.ReadContext(context.Background(), d, client)
+		if diags.HasError() {
+			return d, diags[0].Summary
+		}
+
+		return d, ""
+	}
+	// lvslb-api without /status_ipvs/ endpoint
+	if _, errSummary := read("203.0.113.1"); !regexp.MustCompile(
+		`^\[ERROR\] lvslb-api doesn't support status of backends`).MatchString(errSummary) {
+		t.Errorf("expected error about status not supported, got %q", errSummary)
+	}
+	server.EnableStatus()
+	if _, errSummary := read("203.0.113.2"); !regexp.MustCompile(`virtual server TCP 203\.0\.113\.2:80 not found`).
+		MatchString(errSummary) {
+		t.Errorf("expected error about virtual server not found, got %q", errSummary)
+	}
+	server.SetBackendAlive("192.0.2.2", 80, false)
+	d, errSummary := read("203.0.113.1")
+	if errSummary != "" {
+		t.Fatalf("read data source: %v", errSummary)
+	}
+	if d.Id() != "203.0.113.1_TCP_80" {
+		t.Errorf("unexpected id %v", d.Id())
+	}
+	if d.Get("alive_count") != 1 || d.Get("backends.#") != 2 ||
+		d.Get("backends.0.alive") != true || d.Get("backends.1.alive") != false ||
+		d.Get("backends.1.weight") != 2 {
+		t.Errorf("unexpected status %v", d.State())
+	}
+}
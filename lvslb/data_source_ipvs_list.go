@@ -0,0 +1,173 @@
+package lvslb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+)
+
+func dataSourceIpvsList() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIpvsListRead,
+
+		Schema: map[string]*schema.Schema{
+			"protocol": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"TCP", "UDP", "SCTP"}, true),
+			},
+			"type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"NAT", "DR", "TUN"}, true),
+			},
+			"ip_prefix": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsCIDR,
+			},
+			"ipvs": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"ip": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"port": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"protocol": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"algo": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"persistence_timeout": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"timer_check": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"sorry_server_ip": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"sorry_server_port": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"virtualhost": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"monitoring_period": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"backends": computedIpvsBackendsSchema(),
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceIpvsListRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*lvslbapi.Client)
+	var diags diag.Diagnostics
+	protocol := strings.ToUpper(d.Get("protocol").(string))
+	lbKind := strings.ToUpper(d.Get("type").(string))
+	ipPrefix := d.Get("ip_prefix").(string)
+	var ipNet *net.IPNet
+	if ipPrefix != "" {
+		_, ipNet, _ = net.ParseCIDR(ipPrefix)
+	}
+	ipvsList, err := client.ListVirtualServers(ctx)
+	if err != nil {
+		if !errors.Is(err, lvslbapi.ErrListNotSupported) {
+			return diagFromErr(err)
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  err.Error(),
+			Detail:   "list of virtual servers is empty because lvslb-api doesn't have /list_ipvs/ endpoint",
+		})
+	}
+	// sort by IP in numeric order (IPv4 before IPv6), then protocol and port
+	sort.SliceStable(ipvsList, func(i, j int) bool {
+		if c := bytes.Compare(net.ParseIP(ipvsList[i].IP).To16(), net.ParseIP(ipvsList[j].IP).To16()); c != 0 {
+			return c < 0
+		}
+		if ipvsList[i].IP != ipvsList[j].IP {
+			return ipvsList[i].IP < ipvsList[j].IP
+		}
+		if ipvsList[i].Protocol != ipvsList[j].Protocol {
+			return ipvsList[i].Protocol < ipvsList[j].Protocol
+		}
+
+		return ipvsList[i].Port < ipvsList[j].Port
+	})
+	ipvsFlat := make([]map[string]interface{}, 0, len(ipvsList))
+	for _, v := range ipvsList {
+		if protocol != "" && !strings.EqualFold(string(v.Protocol), protocol) {
+			continue
+		}
+		if lbKind != "" && !strings.EqualFold(string(v.LbKind), lbKind) {
+			continue
+		}
+		if ipNet != nil && !ipNet.Contains(net.ParseIP(v.IP)) {
+			continue
+		}
+		ipvsFlat = append(ipvsFlat, flattenIpvs(v))
+	}
+	if tfErr := d.Set("ipvs", ipvsFlat); tfErr != nil {
+		panic(tfErr)
+	}
+	d.SetId(client.Endpoint() + "_" + protocol + "_" + lbKind + "_" + ipPrefix)
+
+	return diags
+}
+
+func flattenIpvs(ipvsRead lvslbapi.VirtualServer) map[string]interface{} {
+	key := ipvsRead.Key()
+	key.Protocol = lvslbapi.Protocol(strings.ToUpper(string(key.Protocol)))
+
+	return map[string]interface{}{
+		"id":                  key.String(),
+		"ip":                  ipvsRead.IP,
+		"port":                ipvsRead.Port,
+		"protocol":            string(ipvsRead.Protocol),
+		"type":                string(ipvsRead.LbKind),
+		"algo":                string(ipvsRead.LbAlgo),
+		"persistence_timeout": ipvsRead.PersistenceTimeout,
+		"timer_check":         ipvsRead.DelayLoop,
+		"sorry_server_ip":     ipvsRead.SorryIP,
+		"sorry_server_port":   ipvsRead.SorryPort,
+		"virtualhost":         ipvsRead.Virtualhost,
+		"monitoring_period":   ipvsRead.MonPeriod,
+		"backends":            flattenIpvsBackends(ipvsRead.Backends),
+	}
+}
@@ -0,0 +1,35 @@
+package lvslb_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslb"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi/lvslbapitest"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceIpvsList_sort(t *testing.T) {
+	server := lvslbapitest.NewServer("", "")
+	defer server.Close()
+	for _, ip := range []string{"2001:db8::1", "10.0.0.10", "10.0.0.9", "192.0.2.1"} {
+		server.SetVirtualServer(lvslbapi.VirtualServer{IP: ip, Port: 80, Protocol: lvslbapi.ProtocolTCP})
+	}
+	client, err := lvslbapi.New(server.URL)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	dataSource := lvslb.Provider().DataSourcesMap["lvslb_ipvs_list"]
+	d := schema.TestResourceDataRaw(t, dataSource.Schema, map[string]interface{}{})
+	if diags := dataSource.ReadContext(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("read data source: %v", diags)
+	}
+	for i, want := range []string{"10.0.0.9", "10.0.0.10", "192.0.2.1", "2001:db8::1"} {
+		if got := d.Get("ipvs." + strconv.Itoa(i) + ".ip"); got != want {
+			t.Errorf("ipvs.%d.ip: got %v, want %v", i, got, want)
+		}
+	}
+}
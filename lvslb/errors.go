@@ -0,0 +1,69 @@
+package lvslb
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+)
+
+// diagFromErr returns diagnostic with summary and raw response body in detail for APIError
+// and one diagnostic by failed node for PartialError (summary prefixed by node).
+// Summaries of errors returned by lvslbapi are prefixed by [ERROR] like errors of provider.
+func diagFromErr(err error) diag.Diagnostics {
+	diags := diagFromErrNoPrefix(err)
+	for i := range diags {
+		if !strings.HasPrefix(diags[i].Summary, "[ERROR]") {
+			diags[i].Summary = "[ERROR] " + diags[i].Summary
+		}
+	}
+
+	return diags
+}
+
+func diagFromErrNoPrefix(err error) diag.Diagnostics {
+	if err == nil {
+		return nil
+	}
+	var partialErr *lvslbapi.PartialError
+	if errors.As(err, &partialErr) {
+		nodesState := make([]string, 0, 2)
+		if len(partialErr.Applied) > 0 {
+			nodesState = append(nodesState, "change applied on "+strings.Join(partialErr.Applied, ", "))
+		}
+		if len(partialErr.RolledBack) > 0 {
+			nodesState = append(nodesState, "change rolled back on "+strings.Join(partialErr.RolledBack, ", "))
+		}
+		diags := make(diag.Diagnostics, 0, len(partialErr.Failed))
+		for _, v := range partialErr.Failed {
+			for _, nodeDiag := range diagFromErrNoPrefix(v.Err) {
+				nodeDiag.Summary = "node " + v.Node + ": " + nodeDiag.Summary
+				nodeDiag.Detail = strings.TrimSpace(nodeDiag.Detail + "\n" + strings.Join(nodesState, "\n"))
+				diags = append(diags, nodeDiag)
+			}
+		}
+
+		return diags
+	}
+	var nodeErr *lvslbapi.NodeError
+	if errors.As(err, &nodeErr) {
+		diags := diagFromErrNoPrefix(nodeErr.Err)
+		for i := range diags {
+			diags[i].Summary = "node " + nodeErr.Node + ": " + diags[i].Summary
+		}
+
+		return diags
+	}
+	var apiErr *lvslbapi.APIError
+	if errors.As(err, &apiErr) {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			// Error of err instead of APIError to keep context of wrapping errors (e.g. rollback)
+			Summary: err.Error(),
+			Detail:  apiErr.Body,
+		}}
+	}
+
+	return diag.FromErr(err)
+}
@@ -1,83 +1,556 @@
 package lvslb
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
 )
 
-const nullStr string = "null"
 const (
-	defaultFirewallPort = 8080
+	defaultFirewallPort = lvslbapi.DefaultPort
+	defaultMaxRetries   = 3
+	defaultRetryWaitMin = 1
+	defaultRetryWaitMax = 30
+
+	defaultDialTimeout         = int(lvslbapi.DefaultDialTimeout / time.Second)
+	defaultTLSHandshakeTimeout = int(lvslbapi.DefaultTLSHandshakeTimeout / time.Second)
 )
 
 // Provider lvslb for terraform.
 func Provider() *schema.Provider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
+			"endpoint": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("LVSLB_ENDPOINT", nil),
+				ConflictsWith: []string{"firewall_ip", "firewall_ips", "port", "https"},
+			},
 			"firewall_ip": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("LVSLB_FIREWALL_IP", nil),
+				ConflictsWith: []string{"endpoint", "firewall_ips"},
+			},
+			"firewall_ips": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MinItems:      1,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"endpoint", "firewall_ip"},
 			},
 			"port": {
-				Type:     schema.TypeInt,
-				Optional: true,
-				Default:  defaultFirewallPort,
+				Type:        schema.TypeInt,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("LVSLB_PORT", defaultFirewallPort),
 			},
 			"https": {
-				Type:     schema.TypeBool,
-				Optional: true,
-				Default:  false,
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("LVSLB_HTTPS", false),
 			},
 			"insecure": {
-				Type:     schema.TypeBool,
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("LVSLB_INSECURE", false),
+			},
+			"ca_cert_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("LVSLB_CA_CERT_FILE", nil),
+				ConflictsWith: []string{"ca_cert_pem"},
+			},
+			"ca_cert_pem": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("LVSLB_CA_CERT_PEM", nil),
+				ConflictsWith: []string{"ca_cert_file"},
+			},
+			"client_cert": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_CLIENT_CERT", nil),
+				RequiredWith: []string{"client_key"},
+			},
+			"client_key": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_CLIENT_KEY", nil),
+				Sensitive:    true,
+				RequiredWith: []string{"client_cert"},
+			},
+			"tls_server_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("LVSLB_TLS_SERVER_NAME", nil),
+			},
+			"min_tls_version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_MIN_TLS_VERSION", nil),
+				ValidateFunc: validation.StringInSlice([]string{"1.0", "1.1", "1.2", "1.3"}, false),
+			},
+			"logname": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"LVSLB_LOGNAME", "USER"}, nil),
+			},
+			"request_tags": {
+				Type:     schema.TypeMap,
 				Optional: true,
-				Default:  false,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+				ValidateDiagFunc: validation.MapKeyMatch(regexp.MustCompile(`^[a-zA-Z0-9-]+$`),
+					"must only contain alphanumeric characters and dashes"),
+			},
+			"log_request_bodies": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("LVSLB_LOG_REQUEST_BODIES", false),
 			},
 			"login": {
-				Type:     schema.TypeString,
-				Optional: true,
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("LVSLB_LOGIN", nil),
 			},
 			"password": {
-				Type:     schema.TypeString,
-				Optional: true,
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("LVSLB_PASSWORD", nil),
+				Sensitive:   true,
+			},
+			"max_parallel_requests": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_MAX_PARALLEL_REQUESTS", 0),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_MAX_RETRIES", defaultMaxRetries),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"retry_wait_min": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_RETRY_WAIT_MIN", defaultRetryWaitMin),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"retry_wait_max": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_RETRY_WAIT_MAX", defaultRetryWaitMax),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"request_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_REQUEST_TIMEOUT", 0),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"dial_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_DIAL_TIMEOUT", defaultDialTimeout),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"tls_handshake_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_TLS_HANDSHAKE_TIMEOUT", defaultTLSHandshakeTimeout),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"response_header_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("LVSLB_RESPONSE_HEADER_TIMEOUT", 0),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"vault": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{"login", "password", "vault_enable"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"address": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_ADDR", nil),
+						},
+						"namespace": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_NAMESPACE", nil),
+						},
+						"token": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_TOKEN", nil),
+							Sensitive:   true,
+						},
+						"mount": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_MOUNT", "secret"),
+						},
+						"path": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_PATH", "lvs"),
+						},
+						"key": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_KEY", ""),
+						},
+						"kv_version": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							DefaultFunc:  schema.EnvDefaultFunc("LVSLB_VAULT_KV_VERSION", 0),
+							ValidateFunc: validation.IntInSlice([]int{0, vaultKVv1, vaultKVv2}),
+						},
+						"secret_version": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							DefaultFunc:  schema.EnvDefaultFunc("LVSLB_VAULT_SECRET_VERSION", 0),
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+						"login_field": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_LOGIN_FIELD", "login"),
+						},
+						"password_field": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_PASSWORD_FIELD", "password"),
+						},
+						"approle": {
+							Type:          schema.TypeList,
+							Optional:      true,
+							MaxItems:      1,
+							ConflictsWith: []string{"vault.0.kubernetes", "vault.0.jwt"},
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"mount": {
+										Type:     schema.TypeString,
+										Optional: true,
+										Default:  "approle",
+									},
+									"role_id": {
+										Type:        schema.TypeString,
+										Required:    true,
+										DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_APPROLE_ROLE_ID", nil),
+									},
+									"secret_id": {
+										Type:        schema.TypeString,
+										Required:    true,
+										DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_APPROLE_SECRET_ID", nil),
+										Sensitive:   true,
+									},
+								},
+							},
+						},
+						"kubernetes": {
+							Type:          schema.TypeList,
+							Optional:      true,
+							MaxItems:      1,
+							ConflictsWith: []string{"vault.0.approle", "vault.0.jwt"},
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"mount": {
+										Type:     schema.TypeString,
+										Optional: true,
+										Default:  "kubernetes",
+									},
+									"role": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"jwt_file": {
+										Type:     schema.TypeString,
+										Optional: true,
+										Default:  defaultVaultKubernetesJWTFile,
+									},
+								},
+							},
+						},
+						"jwt": {
+							Type:          schema.TypeList,
+							Optional:      true,
+							MaxItems:      1,
+							ConflictsWith: []string{"vault.0.approle", "vault.0.kubernetes"},
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"mount": {
+										Type:     schema.TypeString,
+										Optional: true,
+										Default:  "jwt",
+									},
+									"role": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"jwt": {
+										Type:        schema.TypeString,
+										Required:    true,
+										DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_JWT", nil),
+										Sensitive:   true,
+									},
+								},
+							},
+						},
+					},
+				},
 			},
 			"vault_enable": {
 				Type:          schema.TypeBool,
 				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("LVSLB_VAULT_ENABLE", nil),
 				ConflictsWith: []string{"login", "password"},
+				Deprecated:    "use vault block instead",
 			},
 			"vault_path": {
-				Type:     schema.TypeString,
-				Optional: true,
-				Default:  "lvs",
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_PATH", nil),
+				Deprecated:  "use path in vault block instead",
 			},
 			"vault_key": {
-				Type:     schema.TypeString,
-				Optional: true,
-				Default:  "",
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("LVSLB_VAULT_KEY", nil),
+				Deprecated:  "use key in vault block instead",
+			},
+			"vault_mount": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"vault"},
+				Deprecated:    "use mount in vault block instead",
+			},
+			"vault_kv_version": {
+				Type:          schema.TypeInt,
+				Optional:      true,
+				ValidateFunc:  validation.IntInSlice([]int{0, vaultKVv1, vaultKVv2}),
+				ConflictsWith: []string{"vault"},
+				Deprecated:    "use kv_version in vault block instead",
+			},
+			"vault_secret_version": {
+				Type:          schema.TypeInt,
+				Optional:      true,
+				ValidateFunc:  validation.IntAtLeast(0),
+				ConflictsWith: []string{"vault"},
+				Deprecated:    "use secret_version in vault block instead",
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"lvslb_ipvs": resourceIpvs(),
+			"lvslb_ipvs":         resourceIpvs(),
+			"lvslb_ipvs_backend": resourceIpvsBackend(),
 		},
-		ConfigureFunc: configureProvider,
+		DataSourcesMap: map[string]*schema.Resource{
+			"lvslb_ipvs":                dataSourceIpvs(),
+			"lvslb_ipvs_backend_status": dataSourceIpvsBackendStatus(),
+			"lvslb_ipvs_list":           dataSourceIpvsList(),
+		},
+		ConfigureContextFunc: configureProvider,
 	}
 }
 
-func configureProvider(d *schema.ResourceData) (interface{}, error) {
+func configureProvider(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	config := Config{
-		firewallIP:   d.Get("firewall_ip").(string),
-		firewallPort: d.Get("port").(int),
-		https:        d.Get("https").(bool),
-		insecure:     d.Get("insecure").(bool),
-		logname:      os.Getenv("USER"),
-		login:        d.Get("login").(string),
-		password:     d.Get("password").(string),
-		vaultEnable:  d.Get("vault_enable").(bool),
-		vaultPath:    d.Get("vault_path").(string),
-		vaultKey:     d.Get("vault_key").(string),
-	}
-
-	return config.Client()
+		endpoints:           expandEndpoints(d),
+		firewallPort:        d.Get("port").(int),
+		https:               d.Get("https").(bool),
+		insecure:            d.Get("insecure").(bool),
+		maxParallelRequests: d.Get("max_parallel_requests").(int),
+		maxRetries:          d.Get("max_retries").(int),
+		retryWaitMin:        d.Get("retry_wait_min").(int),
+		retryWaitMax:        d.Get("retry_wait_max").(int),
+		logname:             d.Get("logname").(string),
+		requestTags:         make(map[string]string),
+		logRequestBodies:    d.Get("log_request_bodies").(bool),
+		login:               d.Get("login").(string),
+		password:            d.Get("password").(string),
+		caCertFile:          d.Get("ca_cert_file").(string),
+		caCertPEM:           d.Get("ca_cert_pem").(string),
+		clientCert:          d.Get("client_cert").(string),
+		clientKey:           d.Get("client_key").(string),
+		tlsServerName:       d.Get("tls_server_name").(string),
+		minTLSVersion:       d.Get("min_tls_version").(string),
+		timeouts: lvslbapi.Timeouts{
+			Request:        time.Duration(d.Get("request_timeout").(int)) * time.Second,
+			Dial:           time.Duration(d.Get("dial_timeout").(int)) * time.Second,
+			TLSHandshake:   time.Duration(d.Get("tls_handshake_timeout").(int)) * time.Second,
+			ResponseHeader: time.Duration(d.Get("response_header_timeout").(int)) * time.Second,
+		},
+	}
+
+	if len(config.endpoints) == 0 {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "failed to configure lvslb provider",
+			Detail: "one of endpoint, firewall_ip or firewall_ips must be set " +
+				"(or environment variable LVSLB_ENDPOINT or LVSLB_FIREWALL_IP)",
+		}}
+	}
+	if config.maxRetries > 0 && config.retryWaitMin < 1 {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "failed to configure lvslb provider",
+			Detail:   "retry_wait_min must be at least 1 with max_retries, backoff doubles it after each retry",
+		}}
+	}
+	if config.retryWaitMin > config.retryWaitMax {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "failed to configure lvslb provider",
+			Detail: fmt.Sprintf("retry_wait_min (%d) can't be greater than retry_wait_max (%d)",
+				config.retryWaitMin, config.retryWaitMax),
+		}}
+	}
+	config.vault = expandVaultConfig(d, endpointHost(config.endpoints[0]))
+	for k, v := range d.Get("request_tags").(map[string]interface{}) {
+		config.requestTags[k] = v.(string)
+	}
+	if config.logname == "" {
+		if currentUser, err := user.Current(); err == nil {
+			config.logname = currentUser.Username
+		}
+	}
+	client, err := config.Client(ctx)
+	if err != nil {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "failed to configure lvslb provider",
+			Detail:   err.Error(),
+		}}
+	}
+
+	return client, nil
+}
+
+// expandEndpoints returns endpoint, firewall_ips, firewall_ip or LVSLB_FIREWALL_IPS (comma-separated).
+func expandEndpoints(d *schema.ResourceData) []string {
+	if endpoint := d.Get("endpoint").(string); endpoint != "" {
+		return []string{endpoint}
+	}
+	firewallIPs := make([]string, 0)
+	for _, v := range d.Get("firewall_ips").([]interface{}) {
+		if ip, ok := v.(string); ok && ip != "" {
+			firewallIPs = append(firewallIPs, ip)
+		}
+	}
+	if len(firewallIPs) > 0 {
+		return firewallIPs
+	}
+	if ip := d.Get("firewall_ip").(string); ip != "" {
+		return []string{ip}
+	}
+	for _, ip := range strings.Split(os.Getenv("LVSLB_FIREWALL_IPS"), ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			firewallIPs = append(firewallIPs, ip)
+		}
+	}
+
+	return firewallIPs
+}
+
+// endpointHost returns host (without port) of endpoint (URL or host).
+func endpointHost(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		if endpointURL, err := url.Parse(endpoint); err == nil {
+			return endpointURL.Hostname()
+		}
+
+		return endpoint
+	}
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		return host
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(endpoint, "["), "]")
+}
+
+// expandVaultConfig returns configuration of vault block or of deprecated vault_* arguments
+// (nil if Vault isn't used).
+// defaultKey is used when key isn't set.
+func expandVaultConfig(d *schema.ResourceData, defaultKey string) *vaultConfig {
+	if v, ok := d.GetOk("vault"); ok && len(v.([]interface{})) > 0 && v.([]interface{})[0] != nil {
+		vault := v.([]interface{})[0].(map[string]interface{})
+		vaultConf := &vaultConfig{
+			kvVersion:     vault["kv_version"].(int),
+			secretVersion: vault["secret_version"].(int),
+			address:       vault["address"].(string),
+			namespace:     vault["namespace"].(string),
+			token:         vault["token"].(string),
+			mount:         vault["mount"].(string),
+			path:          vault["path"].(string),
+			key:           vault["key"].(string),
+			loginField:    vault["login_field"].(string),
+			passwordField: vault["password_field"].(string),
+		}
+		if vaultConf.key == "" {
+			vaultConf.key = defaultKey
+		}
+		for _, v := range vault["approle"].([]interface{}) {
+			approle := v.(map[string]interface{})
+			vaultConf.approleEnable = true
+			vaultConf.approleMount = approle["mount"].(string)
+			vaultConf.approleRoleID = approle["role_id"].(string)
+			vaultConf.approleSecretID = approle["secret_id"].(string)
+		}
+		for _, v := range vault["kubernetes"].([]interface{}) {
+			kubernetes := v.(map[string]interface{})
+			vaultConf.kubernetesEnable = true
+			vaultConf.kubernetesMount = kubernetes["mount"].(string)
+			vaultConf.kubernetesRole = kubernetes["role"].(string)
+			vaultConf.kubernetesJWTFile = kubernetes["jwt_file"].(string)
+		}
+		for _, v := range vault["jwt"].([]interface{}) {
+			jwt := v.(map[string]interface{})
+			vaultConf.jwtEnable = true
+			vaultConf.jwtMount = jwt["mount"].(string)
+			vaultConf.jwtRole = jwt["role"].(string)
+			vaultConf.jwt = jwt["jwt"].(string)
+		}
+
+		return vaultConf
+	}
+	if d.Get("vault_enable").(bool) {
+		vaultConf := &vaultConfig{
+			kvVersion:     d.Get("vault_kv_version").(int),
+			secretVersion: d.Get("vault_secret_version").(int),
+			mount:         d.Get("vault_mount").(string),
+			path:          d.Get("vault_path").(string),
+			key:           d.Get("vault_key").(string),
+			loginField:    "login",
+			passwordField: "password",
+		}
+		// defaults of deprecated arguments aren't in schema to not be considered as set
+		if vaultConf.mount == "" {
+			vaultConf.mount = "secret"
+		}
+		if vaultConf.path == "" {
+			vaultConf.path = "lvs"
+		}
+		if vaultConf.key == "" {
+			vaultConf.key = defaultKey
+		}
+
+		return vaultConf
+	}
+
+	return nil
 }
@@ -1,11 +1,34 @@
 package lvslb_test
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jeremmfr/terraform-provider-lvslb/lvslb"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 func TestProvider(t *testing.T) {
@@ -17,3 +40,333 @@ func TestProvider(t *testing.T) {
 func TestProvider_impl(t *testing.T) {
 	var _ *schema.Provider = lvslb.Provider()
 }
+
+func TestProvider_envDefault(t *testing.T) {
+	var gotLogin, gotPassword string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotLogin, gotPassword, _ = r.BasicAuth()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parse test server URL: %v", err)
+	}
+	envs := map[string]string{
+		"LVSLB_FIREWALL_IP": serverURL.Hostname(),
+		"LVSLB_PORT":        serverURL.Port(),
+		"LVSLB_HTTPS":       "false",
+		"LVSLB_LOGIN":       "user",
+		"LVSLB_PASSWORD":    "secret",
+	}
+	for k, v := range envs {
+		t.Setenv(k, v)
+	}
+	provider := lvslb.Provider()
+	diags := provider.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{}))
+	if diags.HasError() {
+		t.Fatalf("configure provider: %v", diags)
+	}
+	client, ok := provider.Meta().(*lvslbapi.Client)
+	if !ok {
+		t.Fatalf("provider meta isn't a *lvslbapi.Client")
+	}
+	if client.Endpoint() != server.URL {
+		t.Errorf("endpoint not read from environment: got %v, want %v", client.Endpoint(), server.URL)
+	}
+	_, err = client.GetVirtualServer(context.Background(), lvslbapi.VirtualServerKey{
+		IP:       "203.0.113.1",
+		Protocol: lvslbapi.ProtocolTCP,
+		Port:     80,
+	})
+	if !errors.Is(err, lvslbapi.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if gotLogin != "user" || gotPassword != "secret" {
+		t.Errorf("login/password not read from environment")
+	}
+}
+
+func TestProvider_vaultDeprecated(t *testing.T) {
+	var gotSecretPath, gotLogin, gotPassword string
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSecretPath = r.URL.Path + "?" + r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"data":{"login":"user","password":"secret"}}}`))
+	}))
+	defer vault.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotLogin, gotPassword, _ = r.BasicAuth()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+	provider := lvslb.Provider()
+	diags := provider.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"endpoint":             server.URL,
+		"vault_enable":         true,
+		"vault_key":            "lb",
+		"vault_mount":          "kv",
+		"vault_kv_version":     2,
+		"vault_secret_version": 3,
+	}))
+	if diags.HasError() {
+		t.Fatalf("configure provider: %v", diags)
+	}
+	if gotSecretPath != "/v1/kv/data/lvs/lb?version=3" {
+		t.Errorf("unexpected Vault secret read: %v", gotSecretPath)
+	}
+	client, ok := provider.Meta().(*lvslbapi.Client)
+	if !ok {
+		t.Fatalf("provider meta isn't a *lvslbapi.Client")
+	}
+	_, _ = client.GetVirtualServer(context.Background(), lvslbapi.VirtualServerKey{
+		IP:       "203.0.113.1",
+		Protocol: lvslbapi.ProtocolTCP,
+		Port:     80,
+	})
+	if gotLogin != "user" || gotPassword != "secret" {
+		t.Errorf("login/password not read from Vault")
+	}
+}
+
+func TestProvider_vaultAuth(t *testing.T) {
+	var gotLoginPath, gotReadToken string
+	var gotLoginData map[string]interface{}
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.URL.Path, "/v1/auth/") {
+			gotLoginPath = r.URL.Path
+			gotLoginData = nil
+			_ = json.NewDecoder(r.Body).Decode(&gotLoginData)
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"token-` + r.URL.Path + `"}}`))
+
+			return
+		}
+		if r.URL.Path != "/v1/kv/lvs/lb" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+		gotReadToken = r.Header.Get("X-Vault-Token")
+		_, _ = w.Write([]byte(`{"data":{"login":"user","password":"secret"}}`))
+	}))
+	defer vault.Close()
+	jwtFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwtFile, []byte("k8s-jwt\n"), 0o600); err != nil {
+		t.Fatalf("write service account token: %v", err)
+	}
+	t.Setenv("VAULT_TOKEN", "")
+	cases := []struct {
+		name      string
+		auth      map[string]interface{}
+		loginPath string
+		loginData map[string]interface{}
+	}{
+		{
+			"approle",
+			map[string]interface{}{"approle": []interface{}{map[string]interface{}{
+				"mount": "approle-lb", "role_id": "role", "secret_id": "secret-id",
+			}}},
+			"/v1/auth/approle-lb/login",
+			map[string]interface{}{"role_id": "role", "secret_id": "secret-id"},
+		},
+		{
+			"kubernetes",
+			map[string]interface{}{"kubernetes": []interface{}{map[string]interface{}{
+				"role": "lb", "jwt_file": jwtFile,
+			}}},
+			"/v1/auth/kubernetes/login",
+			map[string]interface{}{"role": "lb", "jwt": "k8s-jwt"},
+		},
+		{
+			"jwt",
+			map[string]interface{}{"jwt": []interface{}{map[string]interface{}{
+				"mount": "oidc", "role": "lb", "jwt": "token-jwt",
+			}}},
+			"/v1/auth/oidc/login",
+			map[string]interface{}{"role": "lb", "jwt": "token-jwt"},
+		},
+	}
+	for _, c := range cases {
+		gotLoginPath, gotReadToken = "", ""
+		vaultBlock := map[string]interface{}{"address": vault.URL, "mount": "kv", "kv_version": 1, "key": "lb"}
+		for k, v := range c.auth {
+			vaultBlock[k] = v
+		}
+		diags := lvslb.Provider().Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+			"endpoint": "http://203.0.113.1:8080",
+			"vault":    []interface{}{vaultBlock},
+		}))
+		if diags.HasError() {
+			t.Fatalf("%v: configure provider: %v", c.name, diags)
+		}
+		if gotLoginPath != c.loginPath {
+			t.Errorf("%v: unexpected login path %q, want %q", c.name, gotLoginPath, c.loginPath)
+		}
+		for k, v := range c.loginData {
+			if gotLoginData[k] != v {
+				t.Errorf("%v: unexpected %v in login data: %v", c.name, k, gotLoginData[k])
+			}
+		}
+		if gotReadToken != "token-"+c.loginPath {
+			t.Errorf("%v: secret read with token %q instead of token returned by login", c.name, gotReadToken)
+		}
+	}
+}
+
+func TestProvider_retryWait(t *testing.T) {
+	cases := []struct {
+		config map[string]interface{}
+		err    string
+	}{
+		{map[string]interface{}{"retry_wait_min": 0}, "retry_wait_min must be at least 1"},
+		{map[string]interface{}{"retry_wait_min": 0, "max_retries": 0}, ""},
+		{map[string]interface{}{"retry_wait_min": 5, "retry_wait_max": 2}, "can't be greater than retry_wait_max"},
+		{map[string]interface{}{"retry_wait_min": 2, "retry_wait_max": 2}, ""},
+	}
+	for i, c := range cases {
+		c.config["endpoint"] = "http://203.0.113.1:8080"
+		diags := lvslb.Provider().Configure(context.Background(), terraform.NewResourceConfigRaw(c.config))
+		switch {
+		case c.err == "" && diags.HasError():
+			t.Errorf("case %d: unexpected error: %v", i, diags)
+		case c.err != "" && (!diags.HasError() || !strings.Contains(diags[0].Detail, c.err)):
+			t.Errorf("case %d: expected error %q, got %v", i, c.err, diags)
+		}
+	}
+}
+
+// testCert returns a certificate signed by parent (self-signed if parent is nil) in PEM with its key.
+func testCert(t *testing.T, template *x509.Certificate, parent *tls.Certificate) (tls.Certificate, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parentCert, parentKey := template, interface{}(key)
+	if parent != nil {
+		parentCert, parentKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("load certificate: %v", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(der); err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+
+	return cert, certPEM, keyPEM
+}
+
+func TestProvider_tls(t *testing.T) {
+	ca, caPEM, _ := testCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "lvslb test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	serverCert, _, _ := testCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "lvslb-api"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+	_, clientPEM, clientKeyPEM := testCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "terraform"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+	caPool := x509.NewCertPool()
+	caPool.AddCert(ca.Leaf)
+	var gotClient string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotClient = r.TLS.PeerCertificates[0].Subject.CommonName
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    caPool,
+		MaxVersion:   tls.VersionTLS12,
+	}
+	// handshake errors are expected
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	dir := t.TempDir()
+	files := map[string][]byte{"ca.pem": caPEM, "client.pem": clientPEM, "client.key": clientKeyPEM}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			t.Fatalf("write %v: %v", name, err)
+		}
+	}
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+		ok     bool
+	}{
+		{"files", map[string]interface{}{
+			"ca_cert_file": filepath.Join(dir, "ca.pem"),
+			"client_cert":  filepath.Join(dir, "client.pem"),
+			"client_key":   filepath.Join(dir, "client.key"),
+		}, true},
+		{"inline", map[string]interface{}{
+			"ca_cert_pem":     string(caPEM),
+			"client_cert":     string(clientPEM),
+			"client_key":      string(clientKeyPEM),
+			"min_tls_version": "1.2",
+		}, true},
+		{"without client certificate", map[string]interface{}{
+			"ca_cert_pem": string(caPEM),
+		}, false},
+		{"without CA", map[string]interface{}{
+			"client_cert": string(clientPEM),
+			"client_key":  string(clientKeyPEM),
+		}, false},
+		{"min_tls_version above server", map[string]interface{}{
+			"ca_cert_pem":     string(caPEM),
+			"client_cert":     string(clientPEM),
+			"client_key":      string(clientKeyPEM),
+			"min_tls_version": "1.3",
+		}, false},
+	}
+	for _, c := range cases {
+		gotClient = ""
+		c.config["endpoint"] = server.URL
+		c.config["max_retries"] = 0
+		provider := lvslb.Provider()
+		if diags := provider.Configure(context.Background(), terraform.NewResourceConfigRaw(c.config)); diags.HasError() {
+			t.Fatalf("%v: configure provider: %v", c.name, diags)
+		}
+		client, ok := provider.Meta().(*lvslbapi.Client)
+		if !ok {
+			t.Fatalf("provider meta isn't a *lvslbapi.Client")
+		}
+		_, err := client.GetVirtualServer(context.Background(), lvslbapi.VirtualServerKey{
+			IP:       "203.0.113.1",
+			Protocol: lvslbapi.ProtocolTCP,
+			Port:     80,
+		})
+		switch {
+		case c.ok && (!errors.Is(err, lvslbapi.ErrNotFound) || gotClient != "terraform"):
+			t.Errorf("%v: expected request with client certificate, got %v (client %q)", c.name, err, gotClient)
+		case !c.ok && (err == nil || errors.Is(err, lvslbapi.ErrNotFound)):
+			t.Errorf("%v: expected TLS error, got %v", c.name, err)
+		}
+	}
+}
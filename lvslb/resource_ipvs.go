@@ -2,14 +2,17 @@ package lvslb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
 )
 
 const (
@@ -27,6 +30,7 @@ const (
 	maxBackendWeight        = 1000
 	minStatusCode           = 100
 	maxStatusCode           = 600
+	maxBackendRangeSize     = 65536
 )
 
 func resourceIpvs() *schema.Resource {
@@ -35,16 +39,31 @@ func resourceIpvs() *schema.Resource {
 		ReadContext:   resourceIpvsRead,
 		UpdateContext: resourceIpvsUpdate,
 		DeleteContext: resourceIpvsDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceIpvsImport,
+		},
+		CustomizeDiff: customdiff.All(
+			resourceIpvsCustomizeDiff,
+			// protocol is case-insensitive, only a real change of protocol moves the virtual server
+			customdiff.ForceNewIfChange("protocol", func(ctx context.Context, oldValue, newValue, m interface{}) bool {
+				return !strings.EqualFold(oldValue.(string), newValue.(string))
+			}),
+		),
 
 		Schema: map[string]*schema.Schema{
 			"ip": {
 				Type:         schema.TypeString,
 				Required:     true,
+				ForceNew:     true,
 				ValidateFunc: validation.IsIPAddress,
+				DiffSuppressFunc: func(k, oldValue, newValue string, d *schema.ResourceData) bool {
+					return sameIP(oldValue, newValue)
+				},
 			},
 			"port": {
 				Type:         schema.TypeInt,
 				Required:     true,
+				ForceNew:     true,
 				ValidateFunc: validation.IntBetween(0, maxInternetPort),
 			},
 			"protocol": {
@@ -96,9 +115,42 @@ func resourceIpvs() *schema.Resource {
 				Optional: true,
 				Default:  "default",
 			},
+			"max_backend_range_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultMaxBackendRangeSize,
+				ValidateFunc: validation.IntBetween(one, maxBackendRangeSize),
+			},
+			"external_backends": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"resolved_hostnames": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"hostname": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"ips": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+			"missing_nodes": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 			"backends": {
 				Type:     schema.TypeList,
-				Required: true,
+				Optional: true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"ip": {
@@ -170,217 +222,756 @@ func resourceIpvs() *schema.Resource {
 	}
 }
 
-func resourceIpvsCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	client := m.(*Client)
-	err := validateIPBackend(d)
+// resourceIpvsCustomizeDiff resolves hostnames of backends
+// and rejects at plan time combinations of arguments which keepalived refuses or silently ignores.
+// Values unknown at plan time are skipped (IP family of backends is checked again at apply).
+func resourceIpvsCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	// plan creation of virtual server on nodes where it doesn't exist
+	if d.Id() != "" && len(d.Get("missing_nodes").([]interface{})) > 0 {
+		if err := d.SetNew("missing_nodes", []string{}); err != nil {
+			return err
+		}
+	}
+	if d.Get("external_backends").(bool) && len(d.Get("backends").([]interface{})) > 0 {
+		return fmt.Errorf("[ERROR] backends can't be set with external_backends")
+	}
+	if d.Get("sorry_server_port").(int) != 0 &&
+		d.NewValueKnown("sorry_server_ip") && d.Get("sorry_server_ip").(string) == "" {
+		return fmt.Errorf("[ERROR] sorry_server_port is set without sorry_server_ip")
+	}
+	resolved, err := planResolvedHostnames(ctx, d)
 	if err != nil {
-		return diag.FromErr(err)
+		return err
+	}
+	vip := d.Get("ip").(string)
+	vipKnown := d.NewValueKnown("ip")
+	vipPort := d.Get("port").(int)
+	maxSize := d.Get("max_backend_range_size").(int)
+	maxSizeKnown := d.NewValueKnown("max_backend_range_size")
+	drMode := d.NewValueKnown("type") && d.NewValueKnown("port") &&
+		strings.EqualFold(d.Get("type").(string), string(lvslbapi.LbKindDR))
+	// path of first IP for each backend ip:port to detect duplicates across blocks
+	backendsPath := make(map[string]string)
+	for i, dataBackend := range d.Get("backends").([]interface{}) {
+		if dataBackend == nil {
+			continue
+		}
+		backend := dataBackend.(map[string]interface{})
+		path := "backends." + strconv.Itoa(i) + "."
+		if err := validateBackendCheck(d, path); err != nil {
+			return err
+		}
+		if port := backend["port"].(int); drMode && d.NewValueKnown(path+"port") && port != 0 && port != vipPort {
+			return fmt.Errorf("[ERROR] %sport (%d) must be equal to port of virtual server (%d) with type DR",
+				path, port, vipPort)
+		}
+		backendPort := backend["port"].(int)
+		if backendPort == 0 {
+			backendPort = vipPort
+		}
+		portKnown := d.NewValueKnown(path+"port") && (backend["port"].(int) != 0 || d.NewValueKnown("port"))
+		for j, backendIP := range backend["ip"].([]interface{}) {
+			ipPath := path + "ip." + strconv.Itoa(j)
+			entry, ok := backendIP.(string)
+			if !ok || entry == "" || !d.NewValueKnown(ipPath) || (!maxSizeKnown && net.ParseIP(entry) == nil) {
+				continue
+			}
+			ips, err := expandBackendIP(entry, maxSize, resolved)
+			if err != nil {
+				return fmt.Errorf("%w (%s)", err, ipPath)
+			}
+			for _, ip := range ips {
+				if vipKnown {
+					if err := validateIPFamily(vip, ip); err != nil {
+						return fmt.Errorf("%w (%s)", err, ipPath)
+					}
+				}
+				if !portKnown {
+					continue
+				}
+				if parsedIP := net.ParseIP(ip); parsedIP != nil {
+					ip = parsedIP.String()
+				}
+				hostPort := net.JoinHostPort(ip, strconv.Itoa(backendPort))
+				if firstPath, ok := backendsPath[hostPort]; ok {
+					return fmt.Errorf("[ERROR] backend %v is duplicated in %v and %v", hostPort, firstPath, ipPath)
+				}
+				backendsPath[hostPort] = ipPath
+			}
+		}
+	}
+
+	return nil
+}
+
+// planResolvedHostnames resolves hostnames in backends blocks
+// and sets resolved_hostnames to show the result in plan (known after apply if an entry is unknown).
+func planResolvedHostnames(ctx context.Context, d *schema.ResourceDiff) (map[string][]string, error) {
+	resolved := make(map[string][]string)
+	allKnown := d.NewValueKnown("backends")
+	for i, dataBackend := range d.Get("backends").([]interface{}) {
+		if dataBackend == nil {
+			continue
+		}
+		path := "backends." + strconv.Itoa(i) + ".ip"
+		if !d.NewValueKnown(path) {
+			allKnown = false
+
+			continue
+		}
+		for j, backendIP := range dataBackend.(map[string]interface{})["ip"].([]interface{}) {
+			entry, ok := backendIP.(string)
+			if !ok || !d.NewValueKnown(path+"."+strconv.Itoa(j)) {
+				allKnown = false
+
+				continue
+			}
+			if _, ok := resolved[entry]; ok || !isHostname(entry) {
+				continue
+			}
+			vip := ""
+			if d.NewValueKnown("ip") {
+				vip = d.Get("ip").(string)
+			}
+			ips, err := resolveHostname(ctx, entry, vip)
+			if err != nil {
+				return nil, fmt.Errorf("%w (%s.%d)", err, path, j)
+			}
+			resolved[entry] = ips
+		}
+	}
+	if !allKnown {
+		if err := d.SetNewComputed("resolved_hostnames"); err != nil {
+			return nil, err
+		}
+
+		return resolved, nil
+	}
+	if err := d.SetNew("resolved_hostnames", flattenResolvedHostnames(resolved)); err != nil {
+		return nil, err
 	}
-	Ipvs := createStrucIpvs(d)
-	_, err = client.requestAPI(ctx, "ADD", &Ipvs)
+
+	return resolved, nil
+}
+
+// validateBackendCheck checks arguments of health check of backend
+// whose attributes are prefixed by path (empty for lvslb_ipvs_backend).
+func validateBackendCheck(d *schema.ResourceDiff, path string) error {
+	if !d.NewValueKnown(path + "check_type") {
+		return nil
+	}
+	checkType := lvslbapi.CheckType(strings.ToUpper(d.Get(path + "check_type").(string)))
+	switch checkType {
+	case lvslbapi.CheckTypeHTTP, lvslbapi.CheckTypeSSL:
+		if d.NewValueKnown(path+"check_url") && d.Get(path+"check_url").(string) == "" {
+			return fmt.Errorf("[ERROR] %scheck_url is required with check_type %v", path, checkType)
+		}
+	case lvslbapi.CheckTypeMisc:
+		if d.NewValueKnown(path+"misc_path") && d.Get(path+"misc_path").(string) == "" {
+			return fmt.Errorf("[ERROR] %smisc_path is required with check_type %v", path, checkType)
+		}
+	}
+	if checkType != lvslbapi.CheckTypeHTTP && checkType != lvslbapi.CheckTypeSSL {
+		if d.Get(path+"check_digest").(string) != "" {
+			return fmt.Errorf("[ERROR] %scheck_digest can't be set with check_type %v (only %v or %v)",
+				path, checkType, lvslbapi.CheckTypeHTTP, lvslbapi.CheckTypeSSL)
+		}
+		if d.Get(path+"check_status_code").(int) != 0 {
+			return fmt.Errorf("[ERROR] %scheck_status_code can't be set with check_type %v (only %v or %v)",
+				path, checkType, lvslbapi.CheckTypeHTTP, lvslbapi.CheckTypeSSL)
+		}
+	}
+
+	return nil
+}
+
+func resourceIpvsCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*lvslbapi.Client)
+	if err := resolveBackendHostnames(ctx, d); err != nil {
+		return diagFromErr(err)
+	}
+	Ipvs, err := createStrucIpvs(d)
 	if err != nil {
-		return diag.FromErr(err)
+		return diagFromErr(err)
+	}
+	if err := validateIPBackend(Ipvs); err != nil {
+		return diagFromErr(err)
 	}
-	d.SetId(d.Get("ip").(string) + "_" + strings.ToUpper(d.Get("protocol").(string)) +
-		"_" + strconv.Itoa(d.Get("port").(int)))
+	if err := client.AddVirtualServer(ctx, &Ipvs); err != nil {
+		return diagFromErr(err)
+	}
+	d.SetId(Ipvs.Key().String())
 
 	return nil
 }
 
 func resourceIpvsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	client := m.(*Client)
-	Ipvs := createStrucIpvs(d)
-	IpvsRead, err := client.requestAPI(ctx, "CHECK", &Ipvs)
+	client := m.(*lvslbapi.Client)
+	// state created before max_backend_range_size
+	if d.Get("max_backend_range_size").(int) == 0 {
+		if tfErr := d.Set("max_backend_range_size", defaultMaxBackendRangeSize); tfErr != nil {
+			panic(tfErr)
+		}
+	}
+	Ipvs, err := createStrucIpvs(d)
+	if err != nil {
+		return diagFromErr(err)
+	}
+	nodesRead, err := client.GetVirtualServerNodes(ctx, Ipvs.Key())
 	if err != nil {
-		return diag.FromErr(err)
+		return diagFromErr(err)
 	}
-	if IpvsRead.IP == nullStr {
+	IpvsRead, missing, diags := compareIpvsNodes(Ipvs, nodesRead)
+	if diags.HasError() {
+		return diags
+	}
+	if IpvsRead == nil {
 		d.SetId("")
+
+		return diags
 	}
-	if len(IpvsRead.Backends) == 0 {
-		emptyBackend := map[string]interface{}{
-			"ip":                 make([]string, 0),
-			"port":               0,
-			"weight":             0,
-			"check_type":         "",
-			"check_port":         0,
-			"check_timeout":      0,
-			"nb_get_retry":       0,
-			"delay_before_retry": 0,
-			"check_url":          "",
-			"check_digest":       "",
-			"check_status_code":  0,
-			"misc_path":          "",
-		}
-		tfErr := d.Set("backends", []map[string]interface{}{emptyBackend})
-		if tfErr != nil {
-			panic(tfErr)
-		}
+	fillIpvsData(d, IpvsRead)
+	if tfErr := d.Set("missing_nodes", missing); tfErr != nil {
+		panic(tfErr)
 	}
 
-	return nil
+	return diags
 }
 
-func resourceIpvsUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	d.Partial(true)
-	client := m.(*Client)
-	if err := validateIPBackend(d); err != nil {
-		return diag.FromErr(err)
-	}
-	switch {
-	case d.HasChange("ip") || d.HasChange("port"):
-		IpvsOld := createStrucIpvs(d)
-		oldIP, _ := d.GetChange("ip")
-		IpvsOld.IP = oldIP.(string)
-		oldPort, _ := d.GetChange("port")
-		IpvsOld.Port = strconv.Itoa(oldPort.(int))
-		oldProtocol, _ := d.GetChange("protocol")
-		IpvsOld.Protocol = strings.ToUpper(oldProtocol.(string))
-		_, err := client.requestAPI(ctx, "REMOVE", &IpvsOld)
-		if err != nil {
-			return diag.FromErr(err)
+// compareIpvsNodes returns the virtual server read to fill state, nodes where it doesn't exist
+// and a warning if nodes diverge or can't be read.
+// When nodes diverge, the configuration which differs from prior state is returned
+// so the plan shows changes to apply on all nodes.
+// It returns an error if virtual server isn't found on nodes read but can't be read on others.
+func compareIpvsNodes(
+	prior lvslbapi.VirtualServer, nodesRead []lvslbapi.NodeVirtualServer,
+) (*lvslbapi.VirtualServer, []string, diag.Diagnostics) {
+	var ipvsRead *lvslbapi.VirtualServer
+	missing := make([]string, 0)
+	var diags diag.Diagnostics
+	for _, v := range nodesRead {
+		switch {
+		case v.Err != nil:
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "virtual server " + prior.Key().String() + " can't be read on lvslb-api node " + v.Node,
+				Detail:   v.Err.Error(),
+			})
+		case v.VirtualServer == nil:
+			missing = append(missing, v.Node)
+		case ipvsRead == nil:
+			ipvsRead = v.VirtualServer
+		case ipvsRead.Equal(&prior) && !v.VirtualServer.Equal(&prior):
+			ipvsRead = v.VirtualServer
 		}
-		d.SetId("")
-		Ipvs := createStrucIpvs(d)
-		_, err = client.requestAPI(ctx, "ADD", &Ipvs)
-		if err != nil {
-			return diag.FromErr(err)
-		}
-		d.SetId(d.Get("ip").(string) + "_" + strings.ToUpper(d.Get("protocol").(string)) +
-			"_" + strconv.Itoa(d.Get("port").(int)))
-	case d.HasChange("protocol"):
-		oldProtocol, _ := d.GetChange("protocol")
-		if strings.EqualFold(oldProtocol.(string), d.Get("protocol").(string)) {
-			Ipvs := createStrucIpvs(d)
-			_, err := client.requestAPI(ctx, "CHANGE", &Ipvs)
-			if err != nil {
-				return diag.FromErr(err)
-			}
-		} else {
-			IpvsOld := createStrucIpvs(d)
-			IpvsOld.Protocol = strings.ToUpper(oldProtocol.(string))
-			_, err := client.requestAPI(ctx, "REMOVE", &IpvsOld)
-			if err != nil {
-				return diag.FromErr(err)
-			}
-			d.SetId("")
-			Ipvs := createStrucIpvs(d)
-			_, err = client.requestAPI(ctx, "ADD", &Ipvs)
-			if err != nil {
-				return diag.FromErr(err)
+	}
+	if ipvsRead == nil {
+		if len(diags) > 0 {
+			for i := range diags {
+				diags[i].Severity = diag.Error
+				diags[i].Summary = "[ERROR] " + diags[i].Summary
 			}
-			d.SetId(d.Get("ip").(string) + "_" + strings.ToUpper(d.Get("protocol").(string)) +
-				"_" + strconv.Itoa(d.Get("port").(int)))
+
+			return nil, nil, diags
+		}
+
+		return nil, nil, nil
+	}
+	different := make([]string, 0)
+	for _, v := range nodesRead {
+		if v.VirtualServer != nil && !v.VirtualServer.Equal(ipvsRead) {
+			different = append(different, v.Node)
 		}
-	default:
-		Ipvs := createStrucIpvs(d)
-		_, err := client.requestAPI(ctx, "CHANGE", &Ipvs)
+	}
+	if len(missing) == 0 && len(different) == 0 {
+		return ipvsRead, missing, diags
+	}
+	details := make([]string, 0, 2)
+	if len(missing) > 0 {
+		details = append(details, "virtual server doesn't exist on "+strings.Join(missing, ", "))
+	}
+	if len(different) > 0 {
+		details = append(details, "configuration is different on "+strings.Join(different, ", "))
+	}
+
+	return ipvsRead, missing, append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "virtual server " + prior.Key().String() + " diverges between lvslb-api nodes",
+		Detail:   strings.Join(details, "\n"),
+	})
+}
+
+// resourceIpvsUpdate changes virtual server in place,
+// a change of ip, port or protocol (except case) replaces the resource.
+func resourceIpvsUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*lvslbapi.Client)
+	if err := resolveBackendHostnames(ctx, d); err != nil {
+		return diagFromErr(err)
+	}
+	Ipvs, err := createStrucIpvs(d)
+	if err != nil {
+		return diagFromErr(err)
+	}
+	if err := validateIPBackend(Ipvs); err != nil {
+		return diagFromErr(err)
+	}
+	if d.Get("external_backends").(bool) {
+		// change only virtual server, with backends read under lock of virtual server
+		err := client.ModifyVirtualServer(ctx, Ipvs.Key(), func(IpvsRead *lvslbapi.VirtualServer) error {
+			Ipvs.Backends = IpvsRead.Backends
+			*IpvsRead = Ipvs
+
+			return nil
+		})
 		if err != nil {
-			return diag.FromErr(err)
+			return diagFromErr(err)
 		}
+
+		return nil
+	}
+	if err := client.UpdateVirtualServer(ctx, &Ipvs); err != nil {
+		return diagFromErr(err)
 	}
-	d.Partial(false)
 
 	return nil
 }
 
 func resourceIpvsDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	client := m.(*Client)
-	Ipvs := createStrucIpvs(d)
-	_, err := client.requestAPI(ctx, "REMOVE", &Ipvs)
+	client := m.(*lvslbapi.Client)
+	key := lvslbapi.VirtualServerKey{
+		IP:       d.Get("ip").(string),
+		Protocol: lvslbapi.Protocol(strings.ToUpper(d.Get("protocol").(string))),
+		Port:     d.Get("port").(int),
+	}
+	if err := client.RemoveVirtualServer(ctx, key); err != nil {
+		return diagFromErr(err)
+	}
+
+	return nil
+}
+
+func resourceIpvsImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*lvslbapi.Client)
+	ip, protocol, port, err := parseIpvsID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+	key := lvslbapi.VirtualServerKey{
+		IP:       ip,
+		Protocol: lvslbapi.Protocol(protocol),
+		Port:     port,
+	}
+	IpvsRead, err := client.GetVirtualServer(ctx, key)
 	if err != nil {
-		return diag.FromErr(err)
+		if errors.Is(err, lvslbapi.ErrNotFound) {
+			return nil, fmt.Errorf("[ERROR] virtual server %v doesn't exist", d.Id())
+		}
+
+		return nil, err
+	}
+	if tfErr := d.Set("ip", ip); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("protocol", protocol); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("monitoring_period", "default"); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("max_backend_range_size", defaultMaxBackendRangeSize); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("external_backends", false); tfErr != nil {
+		panic(tfErr)
+	}
+	fillIpvsData(d, IpvsRead)
+	d.SetId(key.String())
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// parseIpvsID splits ID in format <ip>_<PROTO>_<port> (IPv6 addresses don't contain '_').
+func parseIpvsID(id string) (string, string, int, error) {
+	idSplit := strings.Split(id, "_")
+	if len(idSplit) != 3 {
+		return "", "", 0, fmt.Errorf("[ERROR] can't find ip, protocol and port in id %v (format <ip>_<PROTO>_<port>)", id)
+	}
+	if net.ParseIP(idSplit[0]) == nil {
+		return "", "", 0, fmt.Errorf("[ERROR] %v isn't a valid IP in id %v", idSplit[0], id)
+	}
+	protocol := strings.ToUpper(idSplit[1])
+	if protocol != "TCP" && protocol != "UDP" && protocol != "SCTP" {
+		return "", "", 0, fmt.Errorf("[ERROR] %v isn't a valid protocol in id %v (TCP|UDP|SCTP)", idSplit[1], id)
+	}
+	port, err := strconv.Atoi(idSplit[2])
+	if err != nil || port < 0 || port > maxInternetPort {
+		return "", "", 0, fmt.Errorf("[ERROR] %v isn't a valid port in id %v", idSplit[2], id)
+	}
+
+	return idSplit[0], protocol, port, nil
+}
+
+// validateIPBackend checks IP family of backends (after expansion of CIDRs, ranges and hostnames) at apply
+// (also checked by resourceIpvsCustomizeDiff when values are known at plan time).
+func validateIPBackend(Ipvs lvslbapi.VirtualServer) error {
+	for _, v := range Ipvs.Backends {
+		if err := validateIPFamily(Ipvs.IP, v.IP); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func validateIPBackend(d *schema.ResourceData) error {
-	if v, ok := d.GetOk("backends"); ok {
-		backendSet := v.([]interface{})
-		for _, dataBackend := range backendSet {
-			backend := dataBackend.(map[string]interface{})
-			for _, backendIP := range backend["ip"].([]interface{}) {
-				testInputIP := net.ParseIP(d.Get("ip").(string))
-				if testInputIP.To4() == nil {
-					testInput := net.ParseIP(backendIP.(string))
-					if testInput.To16() == nil || !strings.Contains(backendIP.(string), ":") {
-						return fmt.Errorf("[ERROR] backend %v isn't an IPv6 for IPv6 virtual server", backendIP)
-					}
-				} else {
-					testInput := net.ParseIP(backendIP.(string))
-					if testInput.To4() == nil {
-						return fmt.Errorf("[ERROR] backend %v isn't an IPv4 for IPv4 virtual server", backendIP)
-					}
-				}
-			}
+// validateIPFamily checks that backendIP has same address family as virtual server IP.
+func validateIPFamily(vip string, backendIP string) error {
+	testInputIP := net.ParseIP(vip)
+	if testInputIP.To4() == nil {
+		testInput := net.ParseIP(backendIP)
+		if testInput.To16() == nil || !strings.Contains(backendIP, ":") {
+			return fmt.Errorf("[ERROR] backend %v isn't an IPv6 for IPv6 virtual server", backendIP)
+		}
+	} else {
+		testInput := net.ParseIP(backendIP)
+		if testInput.To4() == nil {
+			return fmt.Errorf("[ERROR] backend %v isn't an IPv4 for IPv4 virtual server", backendIP)
 		}
 	}
 
 	return nil
 }
 
-func createStrucIpvs(d *schema.ResourceData) ipvs {
-	var backends []ipvsBackend
+// createStrucIpvs returns virtual server with one backend by IP
+// (CIDRs and ranges are expanded, hostnames are replaced by IPs in resolved_hostnames).
+func createStrucIpvs(d *schema.ResourceData) (lvslbapi.VirtualServer, error) {
+	var backends []lvslbapi.Backend
+	resolved := readResolvedHostnames(d)
 	if v, ok := d.GetOk("backends"); ok {
 		backendSet := v.([]interface{})
 		for _, dataBackend := range backendSet {
 			backend := dataBackend.(map[string]interface{})
+			backendIPs := make([]string, 0)
 			for _, backendIP := range backend["ip"].([]interface{}) {
-				var backendPort string
-				if backend["port"].(int) != 0 {
-					backendPort = strconv.Itoa(backend["port"].(int))
-				} else {
-					backendPort = strconv.Itoa(d.Get("port").(int))
+				ips, err := expandBackendIP(backendIP.(string), d.Get("max_backend_range_size").(int), resolved)
+				if err != nil {
+					return lvslbapi.VirtualServer{}, err
 				}
-				var checkPort string
-				switch {
-				case backend["check_port"] != 0:
-					checkPort = strconv.Itoa(backend["check_port"].(int))
-				case backend["port"].(int) != 0:
-					checkPort = strconv.Itoa(backend["port"].(int))
-				default:
-					checkPort = strconv.Itoa(d.Get("port").(int))
+				backendIPs = append(backendIPs, ips...)
+			}
+			for _, backendIP := range backendIPs {
+				backendPort := backend["port"].(int)
+				if backendPort == 0 {
+					backendPort = d.Get("port").(int)
 				}
-				var statusCode string
-				if backend["check_status_code"].(int) != 0 {
-					statusCode = strconv.Itoa(backend["check_status_code"].(int))
-				} else {
-					statusCode = ""
+				checkPort := backend["check_port"].(int)
+				if checkPort == 0 {
+					checkPort = backendPort
 				}
 
-				IpvsBackend := ipvsBackend{
-					IP:               backendIP.(string),
+				IpvsBackend := lvslbapi.Backend{
+					IP:               backendIP,
 					Port:             backendPort,
-					Weight:           strconv.Itoa(backend["weight"].(int)),
-					CheckType:        strings.ToUpper(backend["check_type"].(string)),
+					Weight:           backend["weight"].(int),
+					CheckType:        lvslbapi.CheckType(strings.ToUpper(backend["check_type"].(string))),
 					CheckPort:        checkPort,
-					CheckTimeout:     strconv.Itoa(backend["check_timeout"].(int)),
-					NbGetRetry:       strconv.Itoa(backend["nb_get_retry"].(int)),
-					DelayBeforeRetry: strconv.Itoa(backend["delay_before_retry"].(int)),
+					CheckTimeout:     backend["check_timeout"].(int),
+					NbGetRetry:       backend["nb_get_retry"].(int),
+					DelayBeforeRetry: backend["delay_before_retry"].(int),
 					URLPath:          backend["check_url"].(string),
 					URLDigest:        backend["check_digest"].(string),
-					URLStatusCode:    statusCode,
+					URLStatusCode:    backend["check_status_code"].(int),
 					MiscPath:         backend["misc_path"].(string),
 				}
 				backends = append(backends, IpvsBackend)
 			}
 		}
 	}
-	Ipvs := ipvs{
+	Ipvs := lvslbapi.VirtualServer{
 		IP:                 d.Get("ip").(string),
-		Port:               strconv.Itoa(d.Get("port").(int)),
-		Protocol:           strings.ToUpper(d.Get("protocol").(string)),
-		DelayLoop:          strconv.Itoa(d.Get("timer_check").(int)),
-		LbAlgo:             strings.ToLower(d.Get("algo").(string)),
-		LbKind:             strings.ToUpper(d.Get("type").(string)),
-		PersistenceTimeout: strconv.Itoa(d.Get("persistence_timeout").(int)),
+		Port:               d.Get("port").(int),
+		Protocol:           lvslbapi.Protocol(strings.ToUpper(d.Get("protocol").(string))),
+		DelayLoop:          d.Get("timer_check").(int),
+		LbAlgo:             lvslbapi.LbAlgo(strings.ToLower(d.Get("algo").(string))),
+		LbKind:             lvslbapi.LbKind(strings.ToUpper(d.Get("type").(string))),
+		PersistenceTimeout: d.Get("persistence_timeout").(int),
 		SorryIP:            d.Get("sorry_server_ip").(string),
-		SorryPort:          strconv.Itoa(d.Get("sorry_server_port").(int)),
+		SorryPort:          d.Get("sorry_server_port").(int),
 		Virtualhost:        d.Get("virtualhost").(string),
 		MonPeriod:          d.Get("monitoring_period").(string),
 		Backends:           backends,
 	}
 
-	return Ipvs
+	return Ipvs, nil
+}
+
+// backendOpts are the attributes shared by all IPs of a backends block.
+type backendOpts struct {
+	port             int
+	weight           int
+	checkType        string
+	checkPort        int
+	checkTimeout     int
+	nbGetRetry       int
+	delayBeforeRetry int
+	checkURL         string
+	checkDigest      string
+	checkStatusCode  int
+	miscPath         string
+}
+
+type backendGroup struct {
+	opts backendOpts
+	ips  []string
+}
+
+type backendRead struct {
+	ip   string
+	opts backendOpts
+	used bool
+}
+
+func fillIpvsData(d *schema.ResourceData, ipvsRead *lvslbapi.VirtualServer) {
+	if tfErr := d.Set("ip", sameIPOr(d.Get("ip").(string), ipvsRead.IP)); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("port", ipvsRead.Port); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("protocol", equalFoldOr(d.Get("protocol").(string), string(ipvsRead.Protocol))); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("type", equalFoldOr(d.Get("type").(string), string(ipvsRead.LbKind))); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("algo", equalFoldOr(d.Get("algo").(string), string(ipvsRead.LbAlgo))); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("persistence_timeout", ipvsRead.PersistenceTimeout); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("timer_check", ipvsRead.DelayLoop); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("sorry_server_ip", sameIPOr(d.Get("sorry_server_ip").(string), ipvsRead.SorryIP)); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("sorry_server_port", ipvsRead.SorryPort); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("virtualhost", ipvsRead.Virtualhost); tfErr != nil {
+		panic(tfErr)
+	}
+	// monitoring period isn't part of keepalived configuration,
+	// keep the current value if API doesn't return it
+	if ipvsRead.MonPeriod != "" {
+		if tfErr := d.Set("monitoring_period", ipvsRead.MonPeriod); tfErr != nil {
+			panic(tfErr)
+		}
+	}
+	if d.Get("external_backends").(bool) {
+		return
+	}
+	if tfErr := d.Set("backends", readBackends(d, ipvsRead.Backends, ipvsRead.Port)); tfErr != nil {
+		panic(tfErr)
+	}
+}
+
+// readBackends regroups backends returned by API in backends blocks.
+// IPs already in a block of the current state are kept in this block if attributes are identical
+// (with CIDRs, ranges and hostnames of block when all their IPs are read),
+// others are grouped by identical attributes in new blocks.
+func readBackends(d *schema.ResourceData, ipvsBackendsRead []lvslbapi.Backend, vipPort int) []map[string]interface{} {
+	maxSize := d.Get("max_backend_range_size").(int)
+	resolved := readResolvedHostnames(d)
+	backendsRead := make([]backendRead, 0, len(ipvsBackendsRead))
+	for _, v := range ipvsBackendsRead {
+		backendsRead = append(backendsRead, backendRead{ip: v.IP, opts: backendOptsFromAPI(v)})
+	}
+	groups := make([]backendGroup, 0)
+	for _, dataBackend := range d.Get("backends").([]interface{}) {
+		if dataBackend == nil {
+			continue
+		}
+		backend := dataBackend.(map[string]interface{})
+		priorOpts := backendOptsFromData(backend)
+		priorPort := priorOpts.port
+		if priorPort == 0 {
+			priorPort = vipPort
+		}
+		blockGroups := make([]backendGroup, 0)
+		for _, backendIP := range backend["ip"].([]interface{}) {
+			entry, ok := backendIP.(string)
+			if !ok {
+				continue
+			}
+			ips, err := expandBackendIP(entry, maxSize, resolved)
+			if err != nil {
+				continue
+			}
+			// CIDR, range or hostname is kept only if all its IPs are read with same attributes
+			indexes := make([]int, 0, len(ips))
+			for _, ip := range ips {
+				index := findBackendRead(backendsRead, ip, priorPort)
+				if index == -1 {
+					break
+				}
+				backendsRead[index].used = true
+				indexes = append(indexes, index)
+			}
+			if len(indexes) == 0 {
+				continue
+			}
+			opts := normalizeBackendOpts(backendsRead[indexes[0]].opts, priorOpts, vipPort)
+			sameOpts := len(indexes) == len(ips)
+			for _, index := range indexes[1:] {
+				if normalizeBackendOpts(backendsRead[index].opts, priorOpts, vipPort) != opts {
+					sameOpts = false
+				}
+			}
+			if !sameOpts {
+				for _, index := range indexes {
+					backendsRead[index].used = false
+				}
+
+				continue
+			}
+			blockGroups = appendBackendGroup(blockGroups, opts, entry)
+		}
+		groups = append(groups, blockGroups...)
+	}
+	newGroups := make([]backendGroup, 0)
+	for _, v := range backendsRead {
+		if v.used {
+			continue
+		}
+		newGroups = appendBackendGroup(newGroups, normalizeBackendOpts(v.opts, backendOpts{}, vipPort), v.ip)
+	}
+	groups = append(groups, newGroups...)
+
+	backends := make([]map[string]interface{}, 0, len(groups))
+	for _, v := range groups {
+		backends = append(backends, map[string]interface{}{
+			"ip":                 v.ips,
+			"port":               v.opts.port,
+			"weight":             v.opts.weight,
+			"check_type":         v.opts.checkType,
+			"check_port":         v.opts.checkPort,
+			"check_timeout":      v.opts.checkTimeout,
+			"nb_get_retry":       v.opts.nbGetRetry,
+			"delay_before_retry": v.opts.delayBeforeRetry,
+			"check_url":          v.opts.checkURL,
+			"check_digest":       v.opts.checkDigest,
+			"check_status_code":  v.opts.checkStatusCode,
+			"misc_path":          v.opts.miscPath,
+		})
+	}
+
+	return backends
+}
+
+// findBackendRead returns index of the first unused backend read with ip (on port if possible) or -1.
+func findBackendRead(backendsRead []backendRead, ip string, port int) int {
+	index := -1
+	for i, v := range backendsRead {
+		if v.used || !sameIP(ip, v.ip) {
+			continue
+		}
+		if v.opts.port == port {
+			return i
+		}
+		if index == -1 {
+			index = i
+		}
+	}
+
+	return index
+}
+
+func appendBackendGroup(groups []backendGroup, opts backendOpts, ip string) []backendGroup {
+	for i, v := range groups {
+		if v.opts == opts {
+			groups[i].ips = append(groups[i].ips, ip)
+
+			return groups
+		}
+	}
+
+	return append(groups, backendGroup{opts: opts, ips: []string{ip}})
+}
+
+// normalizeBackendOpts reverts default values computed by createStrucIpvs
+// when they are not explicitly set in prior block.
+func normalizeBackendOpts(opts, prior backendOpts, vipPort int) backendOpts {
+	if prior.port == 0 && opts.port == vipPort {
+		opts.port = 0
+	}
+	backendPort := opts.port
+	if backendPort == 0 {
+		backendPort = vipPort
+	}
+	if prior.checkPort == 0 && opts.checkPort == backendPort {
+		opts.checkPort = 0
+	}
+	opts.checkType = equalFoldOr(prior.checkType, opts.checkType)
+
+	return opts
+}
+
+func backendOptsFromData(backend map[string]interface{}) backendOpts {
+	return backendOpts{
+		port:             backend["port"].(int),
+		weight:           backend["weight"].(int),
+		checkType:        backend["check_type"].(string),
+		checkPort:        backend["check_port"].(int),
+		checkTimeout:     backend["check_timeout"].(int),
+		nbGetRetry:       backend["nb_get_retry"].(int),
+		delayBeforeRetry: backend["delay_before_retry"].(int),
+		checkURL:         backend["check_url"].(string),
+		checkDigest:      backend["check_digest"].(string),
+		checkStatusCode:  backend["check_status_code"].(int),
+		miscPath:         backend["misc_path"].(string),
+	}
+}
+
+func backendOptsFromAPI(backend lvslbapi.Backend) backendOpts {
+	return backendOpts{
+		port:             backend.Port,
+		weight:           backend.Weight,
+		checkType:        string(backend.CheckType),
+		checkPort:        backend.CheckPort,
+		checkTimeout:     backend.CheckTimeout,
+		nbGetRetry:       backend.NbGetRetry,
+		delayBeforeRetry: backend.DelayBeforeRetry,
+		checkURL:         backend.URLPath,
+		checkDigest:      backend.URLDigest,
+		checkStatusCode:  backend.URLStatusCode,
+		miscPath:         backend.MiscPath,
+	}
+}
+
+// equalFoldOr returns prior if equal to read under case-folding (validation is case-insensitive).
+func equalFoldOr(prior, read string) string {
+	if strings.EqualFold(prior, read) {
+		return prior
+	}
+
+	return read
+}
+
+// sameIPOr returns prior if it's the same address as read in another notation.
+func sameIPOr(prior, read string) string {
+	if sameIP(prior, read) {
+		return prior
+	}
+
+	return read
+}
+
+func sameIP(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ipA := net.ParseIP(a)
+
+	return ipA != nil && ipA.Equal(net.ParseIP(b))
 }
@@ -0,0 +1,345 @@
+package lvslb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+)
+
+func resourceIpvsBackend() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceIpvsBackendCreate,
+		ReadContext:   resourceIpvsBackendRead,
+		UpdateContext: resourceIpvsBackendUpdate,
+		DeleteContext: resourceIpvsBackendDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceIpvsBackendImport,
+		},
+		CustomizeDiff: resourceIpvsBackendCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"ipvs_ip": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsIPAddress,
+			},
+			"ipvs_port": {
+				Type:         schema.TypeInt,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IntBetween(0, maxInternetPort),
+			},
+			"ipvs_protocol": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "TCP",
+				ValidateFunc: validation.StringInSlice([]string{"TCP", "UDP", "SCTP"}, true),
+			},
+			"ip": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsIPAddress,
+			},
+			"port": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IntBetween(0, maxInternetPort),
+			},
+			"weight": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      one,
+				ValidateFunc: validation.IntBetween(one, maxBackendWeight),
+			},
+			"check_type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "TCP_CHECK",
+				ValidateFunc: validation.StringInSlice([]string{"TCP_CHECK", "HTTP_GET", "SSL_GET", "MISC_CHECK", "NONE"}, true),
+			},
+			"check_port": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntBetween(one, maxInternetPort),
+			},
+			"check_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultCheckTimeout,
+				ValidateFunc: validation.IntBetween(one, maxCheckTimeout),
+			},
+			"nb_get_retry": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultNbGetRetry,
+				ValidateFunc: validation.IntBetween(one, maxNbGetRetry),
+			},
+			"delay_before_retry": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultDelayBeforeRetry,
+				ValidateFunc: validation.IntBetween(one, maxDelayBeforeRetry),
+			},
+			"check_url": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"check_digest": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"check_status_code": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntBetween(minStatusCode, maxStatusCode),
+			},
+			"misc_path": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+		},
+	}
+}
+
+func resourceIpvsBackendCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if d.NewValueKnown("ipvs_ip") && d.NewValueKnown("ip") {
+		if err := validateIPFamily(d.Get("ipvs_ip").(string), d.Get("ip").(string)); err != nil {
+			return fmt.Errorf("%w (ip)", err)
+		}
+	}
+
+	return validateBackendCheck(d, "")
+}
+
+func resourceIpvsBackendCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*lvslbapi.Client)
+	if err := validateIPFamily(d.Get("ipvs_ip").(string), d.Get("ip").(string)); err != nil {
+		return diagFromErr(err)
+	}
+	key := ipvsKeyOfBackend(d)
+	IpvsBackend := createStrucIpvsBackend(d)
+	err := client.ModifyVirtualServer(ctx, key, func(IpvsRead *lvslbapi.VirtualServer) error {
+		if index := findIpvsBackend(IpvsRead.Backends, IpvsBackend.IP, IpvsBackend.Port); index != -1 {
+			return fmt.Errorf("[ERROR] backend %v:%v already exists on virtual server %v, import it",
+				IpvsBackend.IP, IpvsBackend.Port, key)
+		}
+		IpvsRead.Backends = append(IpvsRead.Backends, IpvsBackend)
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, lvslbapi.ErrNotFound) {
+			return diagFromErr(fmt.Errorf("[ERROR] virtual server %v doesn't exist", key))
+		}
+
+		return diagFromErr(err)
+	}
+	d.SetId(key.String() + "_" + IpvsBackend.IP + "_" + strconv.Itoa(IpvsBackend.Port))
+
+	return resourceIpvsBackendRead(ctx, d, m)
+}
+
+func resourceIpvsBackendRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*lvslbapi.Client)
+	IpvsBackend := createStrucIpvsBackend(d)
+	IpvsRead, err := client.GetVirtualServer(ctx, ipvsKeyOfBackend(d))
+	if err != nil {
+		if errors.Is(err, lvslbapi.ErrNotFound) {
+			d.SetId("")
+
+			return nil
+		}
+
+		return diagFromErr(err)
+	}
+	index := findIpvsBackend(IpvsRead.Backends, IpvsBackend.IP, IpvsBackend.Port)
+	if index == -1 {
+		d.SetId("")
+
+		return nil
+	}
+	opts := backendOptsFromAPI(IpvsRead.Backends[index])
+	if tfErr := d.Set("port", opts.port); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("weight", opts.weight); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("check_type", equalFoldOr(d.Get("check_type").(string), opts.checkType)); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("check_port", opts.checkPort); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("check_timeout", opts.checkTimeout); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("nb_get_retry", opts.nbGetRetry); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("delay_before_retry", opts.delayBeforeRetry); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("check_url", opts.checkURL); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("check_digest", opts.checkDigest); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("check_status_code", opts.checkStatusCode); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("misc_path", opts.miscPath); tfErr != nil {
+		panic(tfErr)
+	}
+
+	return nil
+}
+
+func resourceIpvsBackendUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*lvslbapi.Client)
+	key := ipvsKeyOfBackend(d)
+	IpvsBackend := createStrucIpvsBackend(d)
+	err := client.ModifyVirtualServer(ctx, key, func(IpvsRead *lvslbapi.VirtualServer) error {
+		index := findIpvsBackend(IpvsRead.Backends, IpvsBackend.IP, IpvsBackend.Port)
+		if index == -1 {
+			return fmt.Errorf("[ERROR] backend %v:%v doesn't exist on virtual server %v",
+				IpvsBackend.IP, IpvsBackend.Port, key)
+		}
+		IpvsRead.Backends[index] = IpvsBackend
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, lvslbapi.ErrNotFound) {
+			return diagFromErr(fmt.Errorf("[ERROR] virtual server %v doesn't exist", key))
+		}
+
+		return diagFromErr(err)
+	}
+
+	return resourceIpvsBackendRead(ctx, d, m)
+}
+
+func resourceIpvsBackendDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*lvslbapi.Client)
+	key := ipvsKeyOfBackend(d)
+	IpvsBackend := createStrucIpvsBackend(d)
+	err := client.ModifyVirtualServer(ctx, key, func(IpvsRead *lvslbapi.VirtualServer) error {
+		index := findIpvsBackend(IpvsRead.Backends, IpvsBackend.IP, IpvsBackend.Port)
+		if index == -1 {
+			return lvslbapi.ErrNotModified
+		}
+		IpvsRead.Backends = append(IpvsRead.Backends[:index], IpvsRead.Backends[index+1:]...)
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, lvslbapi.ErrNotFound) {
+		return diagFromErr(err)
+	}
+
+	return nil
+}
+
+func resourceIpvsBackendImport(
+	ctx context.Context, d *schema.ResourceData, m interface{},
+) ([]*schema.ResourceData, error) {
+	idSplit := strings.Split(d.Id(), "_")
+	if len(idSplit) != 5 {
+		return nil, fmt.Errorf("[ERROR] can't find virtual server and backend in id %v "+
+			"(format <ipvs_ip>_<PROTO>_<ipvs_port>_<ip>_<port>)", d.Id())
+	}
+	ipvsIP, protocol, ipvsPort, err := parseIpvsID(strings.Join(idSplit[:3], "_"))
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(idSplit[3]) == nil {
+		return nil, fmt.Errorf("[ERROR] %v isn't a valid IP in id %v", idSplit[3], d.Id())
+	}
+	port, err := strconv.Atoi(idSplit[4])
+	if err != nil || port < 0 || port > maxInternetPort {
+		return nil, fmt.Errorf("[ERROR] %v isn't a valid port in id %v", idSplit[4], d.Id())
+	}
+	if tfErr := d.Set("ipvs_ip", ipvsIP); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("ipvs_port", ipvsPort); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("ipvs_protocol", protocol); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("ip", idSplit[3]); tfErr != nil {
+		panic(tfErr)
+	}
+	if tfErr := d.Set("port", port); tfErr != nil {
+		panic(tfErr)
+	}
+	if diags := resourceIpvsBackendRead(ctx, d, m); diags.HasError() {
+		return nil, fmt.Errorf("[ERROR] %v", diags[0].Summary)
+	}
+	if d.Id() == "" {
+		return nil, fmt.Errorf("[ERROR] backend %v:%v doesn't exist on virtual server %v_%v_%v",
+			idSplit[3], port, ipvsIP, protocol, ipvsPort)
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func findIpvsBackend(backends []lvslbapi.Backend, ip string, port int) int {
+	for i, v := range backends {
+		if sameIP(v.IP, ip) && v.Port == port {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func ipvsKeyOfBackend(d *schema.ResourceData) lvslbapi.VirtualServerKey {
+	return lvslbapi.VirtualServerKey{
+		IP:       d.Get("ipvs_ip").(string),
+		Protocol: lvslbapi.Protocol(strings.ToUpper(d.Get("ipvs_protocol").(string))),
+		Port:     d.Get("ipvs_port").(int),
+	}
+}
+
+func createStrucIpvsBackend(d *schema.ResourceData) lvslbapi.Backend {
+	backendPort := d.Get("port").(int)
+	if backendPort == 0 {
+		backendPort = d.Get("ipvs_port").(int)
+	}
+	checkPort := d.Get("check_port").(int)
+	if checkPort == 0 {
+		checkPort = backendPort
+	}
+
+	return lvslbapi.Backend{
+		IP:               d.Get("ip").(string),
+		Port:             backendPort,
+		Weight:           d.Get("weight").(int),
+		CheckType:        lvslbapi.CheckType(strings.ToUpper(d.Get("check_type").(string))),
+		CheckPort:        checkPort,
+		CheckTimeout:     d.Get("check_timeout").(int),
+		NbGetRetry:       d.Get("nb_get_retry").(int),
+		DelayBeforeRetry: d.Get("delay_before_retry").(int),
+		URLPath:          d.Get("check_url").(string),
+		URLDigest:        d.Get("check_digest").(string),
+		URLStatusCode:    d.Get("check_status_code").(int),
+		MiscPath:         d.Get("misc_path").(string),
+	}
+}
@@ -0,0 +1,231 @@
+package lvslb_test
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslb"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi/lvslbapitest"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func testAccIpvsBackendConfig(server *lvslbapitest.Server, algo string, weight int, extra string) string {
+	return testAccProviderConfig(server, "") + fmt.Sprintf(`
+resource "lvslb_ipvs" "test" {
+  ip   = "203.0.113.4"
+  port = 80
+  algo = %q
+
+  external_backends = true
+}
+
+resource "lvslb_ipvs_backend" "test" {
+  ipvs_ip   = lvslb_ipvs.test.ip
+  ipvs_port = lvslb_ipvs.test.port
+  ip        = "192.0.2.1"
+  weight    = %d
+}
+%s
+`, algo, weight, extra)
+}
+
+func TestAccResourceIpvsBackend_basic(t *testing.T) {
+	server := lvslbapitest.NewServer(testAccLogin, testAccPassword)
+	defer server.Close()
+	key := lvslbapi.VirtualServerKey{IP: "203.0.113.4", Protocol: lvslbapi.ProtocolTCP, Port: 80}
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories(),
+		CheckDestroy:      testAccCheckIpvsDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccIpvsBackendConfig(server, "wlc", 1, ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("lvslb_ipvs_backend.test", "id", "203.0.113.4_TCP_80_192.0.2.1_80"),
+					resource.TestCheckResourceAttr("lvslb_ipvs_backend.test", "port", "80"),
+					resource.TestCheckResourceAttr("lvslb_ipvs_backend.test", "check_port", "80"),
+					testAccCheckIpvsExists(server, key, func(vs lvslbapi.VirtualServer) error {
+						if len(vs.Backends) != 1 || vs.Backends[0].IP != "192.0.2.1" || vs.Backends[0].Weight != 1 {
+							return fmt.Errorf("unexpected backends %+v", vs.Backends)
+						}
+
+						return nil
+					}),
+				),
+			},
+			// change of backend and of virtual server in the same apply
+			{
+				Config: testAccIpvsBackendConfig(server, "rr", 5, ""),
+				Check: testAccCheckIpvsExists(server, key, func(vs lvslbapi.VirtualServer) error {
+					if vs.LbAlgo != lvslbapi.LbAlgoRR || len(vs.Backends) != 1 || vs.Backends[0].Weight != 5 {
+						return fmt.Errorf("unexpected virtual server %+v", vs)
+					}
+
+					return nil
+				}),
+			},
+			// change of virtual server only keeps backend
+			{
+				Config: testAccIpvsBackendConfig(server, "wlc", 5, ""),
+				Check: testAccCheckIpvsExists(server, key, func(vs lvslbapi.VirtualServer) error {
+					if vs.LbAlgo != lvslbapi.LbAlgoWLC || len(vs.Backends) != 1 {
+						return fmt.Errorf("backend not kept on change of virtual server %+v", vs)
+					}
+
+					return nil
+				}),
+			},
+			{
+				ResourceName:      "lvslb_ipvs_backend.test",
+				ImportState:       true,
+				ImportStateId:     "203.0.113.4_TCP_80_192.0.2.1_80",
+				ImportStateVerify: true,
+			},
+			// backend removed outside Terraform
+			{
+				PreConfig: func() {
+					vs, _ := server.VirtualServer(key)
+					vs.Backends = nil
+					server.SetVirtualServer(vs)
+				},
+				Config:             testAccIpvsBackendConfig(server, "wlc", 5, ""),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccIpvsBackendConfig(server, "wlc", 5, ""),
+				Check: testAccCheckIpvsExists(server, key, func(vs lvslbapi.VirtualServer) error {
+					if len(vs.Backends) != 1 {
+						return fmt.Errorf("backend not created again %+v", vs.Backends)
+					}
+
+					return nil
+				}),
+			},
+			// backend created outside Terraform must be imported
+			{
+				PreConfig: func() {
+					vs, _ := server.VirtualServer(key)
+					vs.Backends = append(vs.Backends, lvslbapi.Backend{
+						IP: "192.0.2.2", Port: 80, Weight: 1, CheckType: lvslbapi.CheckTypeTCP,
+					})
+					server.SetVirtualServer(vs)
+				},
+				Config: testAccIpvsBackendConfig(server, "wlc", 5, `
+resource "lvslb_ipvs_backend" "test2" {
+  ipvs_ip   = lvslb_ipvs.test.ip
+  ipvs_port = lvslb_ipvs.test.port
+  ip        = "192.0.2.2"
+}
+`),
+				ExpectError: regexp.MustCompile(`backend 192\.0\.2\.2:80 already exists on virtual server .*, import it`),
+			},
+		},
+	})
+}
+
+func TestResourceIpvsBackend_lifecycle(t *testing.T) {
+	server := lvslbapitest.NewServer("", "")
+	defer server.Close()
+	client, err := lvslbapi.New(server.URL)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	key := lvslbapi.VirtualServerKey{IP: "203.0.113.4", Protocol: lvslbapi.ProtocolTCP, Port: 80}
+	server.SetVirtualServer(lvslbapi.VirtualServer{IP: key.IP, Port: key.Port, Protocol: key.Protocol})
+	resourceBackend := lvslb.Provider().ResourcesMap["lvslb_ipvs_backend"]
+	ctx := context.Background()
+	config := map[string]interface{}{"ipvs_ip": key.IP, "ipvs_port": key.Port, "ip": "192.0.2.1", "weight": 2}
+	diff, err := resourceBackend.Diff(ctx, nil, terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("plan creation: %v", err)
+	}
+	state, diags := resourceBackend.Apply(ctx, nil, diff, client)
+	if diags.HasError() {
+		t.Fatalf("create: %v", diags)
+	}
+	if state.ID != "203.0.113.4_TCP_80_192.0.2.1_80" {
+		t.Errorf("unexpected id %v", state.ID)
+	}
+	// already exists
+	if _, diags := resourceBackend.Apply(ctx, nil, diff, client); !diags.HasError() ||
+		!regexp.MustCompile(`already exists on virtual server .*, import it`).MatchString(diags[0].Summary) {
+		t.Errorf("expected error on creation of existing backend, got %v", diags)
+	}
+	config["weight"] = 3
+	diff, err = resourceBackend.Diff(ctx, state, terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("plan change: %v", err)
+	}
+	state, diags = resourceBackend.Apply(ctx, state, diff, client)
+	if diags.HasError() {
+		t.Fatalf("update: %v", diags)
+	}
+	if vs, _ := server.VirtualServer(key); len(vs.Backends) != 1 || vs.Backends[0].Weight != 3 {
+		t.Errorf("backend not changed: %+v", vs.Backends)
+	}
+	// delete when backend and then virtual server are already gone
+	for _, remove := range []func(){
+		func() {
+			server.SetVirtualServer(lvslbapi.VirtualServer{IP: key.IP, Port: key.Port, Protocol: key.Protocol})
+		},
+		func() { server.DeleteVirtualServer(key) },
+	} {
+		remove()
+		changes := server.Requests(lvslbapitest.ActionChange)
+		d := resourceBackend.Data(state)
+		if diags := resourceBackend.DeleteContext(ctx, d, client); diags.HasError() {
+			t.Errorf("delete of missing backend: %v", diags)
+		}
+		if server.Requests(lvslbapitest.ActionChange) != changes {
+			t.Errorf("virtual server changed on delete of missing backend")
+		}
+	}
+}
+
+func TestResourceIpvsBackend_import(t *testing.T) {
+	server := lvslbapitest.NewServer("", "")
+	defer server.Close()
+	client, err := lvslbapi.New(server.URL)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	server.SetVirtualServer(lvslbapi.VirtualServer{
+		IP: "2001:db8::10", Port: 443, Protocol: lvslbapi.ProtocolTCP,
+		Backends: []lvslbapi.Backend{{
+			IP: "2001:db8::1", Port: 8443, Weight: 4, CheckType: lvslbapi.CheckTypeTCP, CheckPort: 8443,
+		}},
+	})
+	resourceBackend := lvslb.Provider().ResourcesMap["lvslb_ipvs_backend"]
+	cases := []struct {
+		id  string
+		err string
+	}{
+		{"2001:db8::10_tcp_443_2001:db8::1_8443", ""},
+		{"2001:db8::10_TCP_443_2001:db8::1", `format <ipvs_ip>_<PROTO>_<ipvs_port>_<ip>_<port>`},
+		{"2001:db8::10_ICMP_443_2001:db8::1_8443", `ICMP isn't a valid protocol`},
+		{"2001:db8::10_TCP_443_backend_8443", `backend isn't a valid IP`},
+		{"2001:db8::10_TCP_443_2001:db8::1_70000", `70000 isn't a valid port`},
+		{"2001:db8::10_TCP_443_2001:db8::2_8443", `backend 2001:db8::2:8443 doesn't exist on virtual server`},
+	}
+	for i, c := range cases {
+		d := resourceBackend.Data(nil)
+		d.SetId(c.id)
+		imported, err := resourceBackend.Importer.StateContext(context.Background(), d, client)
+		switch {
+		case c.err != "" && (err == nil || !regexp.MustCompile(c.err).MatchString(err.Error())):
+			t.Errorf("case %d: expected error matching %q, got %v", i, c.err, err)
+		case c.err == "" && err != nil:
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		case c.err == "":
+			if imported[0].Get("ipvs_protocol") != "TCP" || imported[0].Get("weight") != 4 ||
+				imported[0].Get("port") != 8443 {
+				t.Errorf("case %d: unexpected import %v", i, imported[0].State())
+			}
+		}
+	}
+}
@@ -0,0 +1,685 @@
+package lvslb_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslb"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi/lvslbapitest"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+const (
+	testAccLogin    = "terraform"
+	testAccPassword = "secret"
+)
+
+func testAccProviderFactories() map[string]func() (*schema.Provider, error) {
+	return map[string]func() (*schema.Provider, error){
+		"lvslb": func() (*schema.Provider, error) {
+			return lvslb.Provider(), nil
+		},
+	}
+}
+
+func testAccProviderConfig(server *lvslbapitest.Server, extra string) string {
+	return fmt.Sprintf(`
+provider "lvslb" {
+  endpoint       = %q
+  login          = %q
+  password       = %q
+  retry_wait_min = 1
+  retry_wait_max = 1
+  %s
+}
+`, server.URL, testAccLogin, testAccPassword, extra)
+}
+
+func testAccIpvsConfig(server *lvslbapitest.Server, ip, protocol string, port int, algo, weight string) string {
+	return testAccProviderConfig(server, "") + fmt.Sprintf(`
+resource "lvslb_ipvs" "test" {
+  ip       = %q
+  port     = %d
+  protocol = %q
+  algo     = %q
+  backends {
+    ip     = ["192.0.2.1", "192.0.2.2"]
+    weight = %s
+  }
+  backends {
+    ip         = ["192.0.2.3"]
+    port       = 8443
+    check_type = "HTTP_GET"
+    check_url  = "/health"
+  }
+}
+`, ip, port, protocol, algo, weight)
+}
+
+func TestAccResourceIpvs_basic(t *testing.T) {
+	server := lvslbapitest.NewServer(testAccLogin, testAccPassword)
+	defer server.Close()
+	key := lvslbapi.VirtualServerKey{IP: "203.0.113.1", Protocol: lvslbapi.ProtocolTCP, Port: 80}
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories(),
+		CheckDestroy:      testAccCheckIpvsDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccIpvsConfig(server, key.IP, "TCP", key.Port, "wlc", "1"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "id", "203.0.113.1_TCP_80"),
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "backends.#", "2"),
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "backends.0.ip.#", "2"),
+					testAccCheckIpvsExists(server, key, func(vs lvslbapi.VirtualServer) error {
+						if len(vs.Backends) != 3 || vs.Backends[2].Port != 8443 || vs.Backends[2].CheckPort != 8443 {
+							return fmt.Errorf("unexpected backends %+v", vs.Backends)
+						}
+
+						return nil
+					}),
+				),
+			},
+			// change without ip/port/protocol => CHANGE
+			{
+				Config: testAccIpvsConfig(server, key.IP, "TCP", key.Port, "rr", "10"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "algo", "rr"),
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "backends.0.weight", "10"),
+					testAccCheckIpvsExists(server, key, func(vs lvslbapi.VirtualServer) error {
+						if vs.LbAlgo != lvslbapi.LbAlgoRR || vs.Backends[0].Weight != 10 {
+							return fmt.Errorf("virtual server not changed %+v", vs)
+						}
+
+						return nil
+					}),
+				),
+			},
+			// protocol with different case => CHANGE
+			{
+				Config: testAccIpvsConfig(server, key.IP, "tcp", key.Port, "rr", "10"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "protocol", "tcp"),
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "id", "203.0.113.1_TCP_80"),
+					testAccCheckIpvsExists(server, key, nil),
+				),
+			},
+			// change of port => virtual server replaced
+			{
+				Config: testAccIpvsConfig(server, key.IP, "TCP", 8080, "rr", "10"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "id", "203.0.113.1_TCP_8080"),
+					testAccCheckIpvsExists(server, lvslbapi.VirtualServerKey{
+						IP: key.IP, Protocol: lvslbapi.ProtocolTCP, Port: 8080,
+					}, nil),
+					testAccCheckIpvsNotExists(server, key),
+				),
+			},
+			// change of protocol => virtual server replaced
+			{
+				Config: testAccIpvsConfig(server, key.IP, "UDP", 8080, "rr", "10"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "id", "203.0.113.1_UDP_8080"),
+					testAccCheckIpvsNotExists(server, lvslbapi.VirtualServerKey{
+						IP: key.IP, Protocol: lvslbapi.ProtocolTCP, Port: 8080,
+					}),
+				),
+			},
+			{
+				ResourceName:      "lvslb_ipvs.test",
+				ImportState:       true,
+				ImportStateId:     "203.0.113.1_UDP_8080",
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceIpvs_createBeforeDestroy(t *testing.T) {
+	server := lvslbapitest.NewServer(testAccLogin, testAccPassword)
+	defer server.Close()
+	config := func(ip string) string {
+		return testAccProviderConfig(server, "") + fmt.Sprintf(`
+resource "lvslb_ipvs" "test" {
+  ip   = %q
+  port = 80
+  backends {
+    ip = ["192.0.2.1"]
+  }
+  lifecycle {
+    create_before_destroy = true
+  }
+}
+`, ip)
+	}
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories(),
+		CheckDestroy:      testAccCheckIpvsDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: config("203.0.113.4"),
+			},
+			// ADD of new virtual server fails => old virtual server is kept
+			{
+				PreConfig: func() {
+					server.InjectFault(lvslbapitest.ActionAdd, http.StatusBadRequest, 1)
+				},
+				Config:      config("203.0.113.5"),
+				ExpectError: regexp.MustCompile(`returned 400 Bad Request`),
+			},
+			{
+				PreConfig: func() {
+					if _, ok := server.VirtualServer(lvslbapi.VirtualServerKey{
+						IP: "203.0.113.4", Protocol: lvslbapi.ProtocolTCP, Port: 80,
+					}); !ok {
+						t.Error("virtual server removed before creation of its replacement")
+					}
+				},
+				Config: config("203.0.113.5"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "id", "203.0.113.5_TCP_80"),
+					testAccCheckIpvsNotExists(server, lvslbapi.VirtualServerKey{
+						IP: "203.0.113.4", Protocol: lvslbapi.ProtocolTCP, Port: 80,
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestResourceIpvs_resolvedHostnames(t *testing.T) {
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"ip": "203.0.113.1", "port": 80,
+		"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.1", "localhost"}}},
+	})
+	diff, err := lvslb.Provider().ResourcesMap["lvslb_ipvs"].Diff(context.Background(), nil, config, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for k, want := range map[string]string{
+		"resolved_hostnames.#":          "1",
+		"resolved_hostnames.0.hostname": "localhost",
+		"resolved_hostnames.0.ips.0":    "127.0.0.1",
+	} {
+		if attr, ok := diff.Attributes[k]; !ok || attr.New != want {
+			t.Errorf("%v in plan: got %+v, want %v", k, attr, want)
+		}
+	}
+}
+
+func TestAccResourceIpvs_backendRanges(t *testing.T) {
+	server := lvslbapitest.NewServer(testAccLogin, testAccPassword)
+	defer server.Close()
+	key := lvslbapi.VirtualServerKey{IP: "203.0.113.6", Protocol: lvslbapi.ProtocolTCP, Port: 80}
+	config := func(ips string) string {
+		return testAccProviderConfig(server, "") + fmt.Sprintf(`
+resource "lvslb_ipvs" "test" {
+  ip   = "203.0.113.6"
+  port = 80
+  backends {
+    ip = [%s]
+  }
+}
+`, ips)
+	}
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories(),
+		CheckDestroy:      testAccCheckIpvsDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: config(`"192.0.2.0/30", "192.0.2.10-192.0.2.11", "localhost"`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "backends.0.ip.0", "192.0.2.0/30"),
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "resolved_hostnames.0.hostname", "localhost"),
+					resource.TestCheckResourceAttr("lvslb_ipvs.test", "resolved_hostnames.0.ips.0", "127.0.0.1"),
+					testAccCheckIpvsExists(server, key, func(vs lvslbapi.VirtualServer) error {
+						if len(vs.Backends) != 5 || vs.Backends[0].IP != "192.0.2.1" || vs.Backends[1].IP != "192.0.2.2" ||
+							vs.Backends[4].IP != "127.0.0.1" {
+							return fmt.Errorf("unexpected backends %+v", vs.Backends)
+						}
+
+						return nil
+					}),
+				),
+			},
+			// IP of range removed outside Terraform
+			{
+				PreConfig: func() {
+					vs, _ := server.VirtualServer(key)
+					vs.Backends = vs.Backends[:len(vs.Backends)-2]
+					server.SetVirtualServer(vs)
+				},
+				Config:             config(`"192.0.2.0/30", "192.0.2.10-192.0.2.11", "localhost"`),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: config(`"192.0.2.0/30", "192.0.2.10-192.0.2.11", "localhost"`),
+				Check: testAccCheckIpvsExists(server, key, func(vs lvslbapi.VirtualServer) error {
+					if len(vs.Backends) != 5 {
+						return fmt.Errorf("drift not fixed, backends %+v", vs.Backends)
+					}
+
+					return nil
+				}),
+			},
+		},
+	})
+}
+
+func TestAccResourceIpvs_drift(t *testing.T) {
+	server := lvslbapitest.NewServer(testAccLogin, testAccPassword)
+	defer server.Close()
+	key := lvslbapi.VirtualServerKey{IP: "203.0.113.2", Protocol: lvslbapi.ProtocolTCP, Port: 443}
+	config := testAccIpvsConfig(server, key.IP, "TCP", key.Port, "wlc", "1")
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories(),
+		CheckDestroy:      testAccCheckIpvsDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			// weight changed outside Terraform
+			{
+				PreConfig: func() {
+					vs, _ := server.VirtualServer(key)
+					vs.Backends[0].Weight = 50
+					server.SetVirtualServer(vs)
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: config,
+				Check: testAccCheckIpvsExists(server, key, func(vs lvslbapi.VirtualServer) error {
+					if vs.Backends[0].Weight != 1 {
+						return fmt.Errorf("drift not fixed, weight %v", vs.Backends[0].Weight)
+					}
+
+					return nil
+				}),
+			},
+			// virtual server removed outside Terraform
+			{
+				PreConfig: func() {
+					server.DeleteVirtualServer(key)
+				},
+				Config: config,
+				Check:  testAccCheckIpvsExists(server, key, nil),
+			},
+		},
+	})
+}
+
+func TestAccResourceIpvs_faults(t *testing.T) {
+	server := lvslbapitest.NewServer(testAccLogin, testAccPassword)
+	defer server.Close()
+	key := lvslbapi.VirtualServerKey{IP: "203.0.113.3", Protocol: lvslbapi.ProtocolTCP, Port: 80}
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories(),
+		CheckDestroy:      testAccCheckIpvsDestroy(server),
+		Steps: []resource.TestStep{
+			// transient failure is retried
+			{
+				PreConfig: func() {
+					server.InjectFault(lvslbapitest.ActionAdd, http.StatusServiceUnavailable, 1)
+				},
+				Config: testAccIpvsConfig(server, key.IP, "TCP", key.Port, "wlc", "1"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckIpvsExists(server, key, nil),
+					func(*terraform.State) error {
+						if server.Requests(lvslbapitest.ActionAdd) != 2 {
+							return fmt.Errorf("expected 2 ADD requests, got %v", server.Requests(lvslbapitest.ActionAdd))
+						}
+
+						return nil
+					},
+				),
+			},
+			// error of API is reported with its message
+			{
+				PreConfig: func() {
+					server.InjectFault(lvslbapitest.ActionChange, http.StatusBadRequest, 1)
+				},
+				Config:      testAccIpvsConfig(server, key.IP, "TCP", key.Port, "rr", "1"),
+				ExpectError: regexp.MustCompile(`returned 400 Bad Request: injected fault`),
+			},
+			// request rejected by authentication
+			{
+				Config: fmt.Sprintf(`
+provider "lvslb" {
+  endpoint = %q
+  login    = %q
+  password = "wrong"
+}
+
+resource "lvslb_ipvs" "test" {
+  ip   = "203.0.113.3"
+  port = 80
+}
+`, server.URL, testAccLogin),
+				ExpectError: regexp.MustCompile(`returned 401 Unauthorized`),
+			},
+			// back to valid credentials to destroy
+			{
+				Config: testAccIpvsConfig(server, key.IP, "TCP", key.Port, "wlc", "1"),
+				Check:  testAccCheckIpvsExists(server, key, nil),
+			},
+		},
+	})
+}
+
+func TestResourceIpvs_customizeDiff(t *testing.T) {
+	cases := []struct {
+		resource string
+		config   map[string]interface{}
+		err      string
+	}{
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80, "type": "DR",
+			"backends": []interface{}{map[string]interface{}{
+				"ip": []interface{}{"192.0.2.1"}, "check_type": "HTTP_GET", "check_url": "/", "check_status_code": 200,
+			}},
+		}, ""},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{
+				map[string]interface{}{"ip": []interface{}{"192.0.2.1"}},
+				map[string]interface{}{"ip": []interface{}{"192.0.2.2"}, "check_type": "ssl_get"},
+			},
+		}, `backends\.1\.check_url is required`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.1"}, "check_type": "MISC_CHECK"}},
+		}, `backends\.0\.misc_path is required`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.1"}, "check_digest": "abc"}},
+		}, `backends\.0\.check_digest can't be set with check_type TCP_CHECK`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.1"}, "check_status_code": 200}},
+		}, `backends\.0\.check_status_code can't be set`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80, "external_backends": true,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.1"}}},
+		}, `backends can't be set with external_backends`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80, "sorry_server_port": 8080,
+		}, `sorry_server_port is set without sorry_server_ip`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80, "type": "dr",
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.1"}, "port": 8080}},
+		}, `backends\.0\.port \(8080\) must be equal to port of virtual server \(80\)`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.1", "2001:db8::1"}}},
+		}, `isn't an IPv4 for IPv4 virtual server \(backends\.0\.ip\.1\)`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{
+				map[string]interface{}{"ip": []interface{}{"192.0.2.1", "192.0.2.2"}},
+				map[string]interface{}{"ip": []interface{}{"192.0.2.2"}, "port": 8080},
+				map[string]interface{}{"ip": []interface{}{"192.0.2.3", "192.0.2.2"}, "weight": 2},
+			},
+		}, `backend 192\.0\.2\.2:80 is duplicated in backends\.0\.ip\.1 and backends\.2\.ip\.1`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "2001:db8::10", "port": 80,
+			"backends": []interface{}{
+				map[string]interface{}{"ip": []interface{}{"2001:db8::1"}, "port": 80},
+				map[string]interface{}{"ip": []interface{}{"2001:DB8:0::1"}},
+			},
+		}, `backend \[2001:db8::1\]:80 is duplicated in backends\.0\.ip\.0 and backends\.1\.ip\.0`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{
+				map[string]interface{}{"ip": []interface{}{"192.0.2.0/24", "192.0.2.1-192.0.2.10", "localhost"}},
+			},
+		}, `backend 192\.0\.2\.1:80 is duplicated in backends\.0\.ip\.0 and backends\.0\.ip\.1`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{
+				map[string]interface{}{"ip": []interface{}{"192.0.2.0/30", "192.0.2.0", "192.0.2.3"}},
+			},
+		}, ``},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.0/30", "192.0.2.2"}}},
+		}, `backend 192\.0\.2\.2:80 is duplicated in backends\.0\.ip\.0 and backends\.0\.ip\.1`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.4/31", "192.0.2.4"}}},
+		}, `backend 192\.0\.2\.4:80 is duplicated in backends\.0\.ip\.0 and backends\.0\.ip\.1`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80, "max_backend_range_size": 8,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.0/29", "192.0.2.0/28"}}},
+		}, `CIDR 192\.0\.2\.0/28 contains more than 8 addresses \(max_backend_range_size\) \(backends\.0\.ip\.1\)`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.10-192.0.2.1"}}},
+		}, `start of range 192\.0\.2\.10-192\.0\.2\.1 is greater than end`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"2001:db8::/126"}}},
+		}, `backend 2001:db8:: isn't an IPv4 for IPv4 virtual server \(backends\.0\.ip\.0\)`},
+		{"lvslb_ipvs", map[string]interface{}{
+			"ip": "203.0.113.1", "port": 80,
+			"backends": []interface{}{map[string]interface{}{"ip": []interface{}{"192.0.2.0/33"}}},
+		}, `192\.0\.2\.0/33 isn't a valid CIDR`},
+		{"lvslb_ipvs_backend", map[string]interface{}{
+			"ipvs_ip": "203.0.113.1", "ipvs_port": 80, "ip": "192.0.2.1", "check_type": "HTTP_GET",
+		}, `check_url is required with check_type HTTP_GET`},
+	}
+	provider := lvslb.Provider()
+	for i, c := range cases {
+		_, err := provider.ResourcesMap[c.resource].Diff(
+			context.Background(), nil, terraform.NewResourceConfigRaw(c.config), nil)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		case c.err != "" && (err == nil || !regexp.MustCompile(c.err).MatchString(err.Error())):
+			t.Errorf("case %d: expected error matching %q, got %v", i, c.err, err)
+		}
+	}
+}
+
+func TestResourceIpvs_forceNew(t *testing.T) {
+	state := &terraform.InstanceState{
+		ID: "2001:db8::1_TCP_80",
+		Attributes: map[string]string{
+			"id":                  "2001:db8::1_TCP_80",
+			"ip":                  "2001:db8::1",
+			"port":                "80",
+			"protocol":            "TCP",
+			"type":                "NAT",
+			"algo":                "wlc",
+			"persistence_timeout": "0",
+			"timer_check":         "5",
+			"monitoring_period":   "default",
+			"backends.#":          "0",
+		},
+	}
+	cases := []struct {
+		config      map[string]interface{}
+		requiresNew bool
+	}{
+		{map[string]interface{}{"ip": "2001:db8::1", "port": 80, "algo": "rr"}, false},
+		{map[string]interface{}{"ip": "2001:DB8:0::1", "port": 80, "algo": "rr"}, false},
+		{map[string]interface{}{"ip": "2001:db8::1", "port": 80, "protocol": "tcp"}, false},
+		{map[string]interface{}{"ip": "2001:db8::2", "port": 80}, true},
+		{map[string]interface{}{"ip": "2001:db8::1", "port": 443}, true},
+		{map[string]interface{}{"ip": "2001:db8::1", "port": 80, "protocol": "udp"}, true},
+	}
+	resourceIpvs := lvslb.Provider().ResourcesMap["lvslb_ipvs"]
+	for i, c := range cases {
+		diff, err := resourceIpvs.Diff(context.Background(), state, terraform.NewResourceConfigRaw(c.config), nil)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+
+			continue
+		}
+		if diff.RequiresNew() != c.requiresNew {
+			t.Errorf("case %d: requires new %v, want %v (%v)", i, diff.RequiresNew(), c.requiresNew, diff)
+		}
+	}
+}
+
+func TestResourceIpvs_missingNode(t *testing.T) {
+	master := lvslbapitest.NewServer("", "")
+	defer master.Close()
+	backup := lvslbapitest.NewServer("", "")
+	defer backup.Close()
+	client, err := lvslbapi.New(master.URL, lvslbapi.WithPeers(backup.URL))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	vs := lvslbapi.VirtualServer{
+		IP: "203.0.113.1", Port: 80, Protocol: lvslbapi.ProtocolTCP,
+		LbAlgo: lvslbapi.LbAlgoWLC, LbKind: lvslbapi.LbKindNAT, DelayLoop: 5,
+	}
+	master.SetVirtualServer(vs)
+	resourceIpvs := lvslb.Provider().ResourcesMap["lvslb_ipvs"]
+	config := map[string]interface{}{"ip": "203.0.113.1", "port": 80}
+	d := schema.TestResourceDataRaw(t, resourceIpvs.Schema, config)
+	d.SetId(vs.Key().String())
+	diags := resourceIpvs.ReadContext(context.Background(), d, client)
+	if diags.HasError() || len(diags) != 1 {
+		t.Fatalf("expected a warning for missing node, got %v", diags)
+	}
+	if missing := d.Get("missing_nodes").([]interface{}); len(missing) != 1 || missing[0] != client.Nodes()[1] {
+		t.Errorf("unexpected missing_nodes: %v", missing)
+	}
+	diff, err := resourceIpvs.Diff(context.Background(), d.State(), terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attr, ok := diff.Attributes["missing_nodes.#"]; !ok || attr.New != "0" {
+		t.Fatalf("plan doesn't create virtual server on missing node: %v", diff)
+	}
+	if _, diags := resourceIpvs.Apply(context.Background(), d.State(), diff, client); diags.HasError() {
+		t.Fatalf("apply: %v", diags)
+	}
+	if _, ok := backup.VirtualServer(vs.Key()); !ok {
+		t.Errorf("virtual server not created on missing node")
+	}
+}
+
+func TestResourceIpvs_rollbackError(t *testing.T) {
+	master := lvslbapitest.NewServer("", "")
+	defer master.Close()
+	backup := lvslbapitest.NewServer("", "")
+	defer backup.Close()
+	client, err := lvslbapi.New(master.URL, lvslbapi.WithPeers(backup.URL), lvslbapi.WithRetry(0, 0, 0))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	// creation fails on backup and rollback fails on master
+	backup.InjectFault(lvslbapitest.ActionAdd, http.StatusBadRequest, 1)
+	master.InjectFault(lvslbapitest.ActionRemove, http.StatusBadRequest, 1)
+	resourceIpvs := lvslb.Provider().ResourcesMap["lvslb_ipvs"]
+	config := map[string]interface{}{"ip": "203.0.113.1", "port": 80}
+	diff, err := resourceIpvs.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("plan creation: %v", err)
+	}
+	_, diags := resourceIpvs.Apply(context.Background(), nil, diff, client)
+	want := []string{
+		"[ERROR] node " + client.Nodes()[1] + ": lvslb-api ADD /add_ipvs/TCP/203.0.113.1/80/ returned 400",
+		"[ERROR] node " + client.Nodes()[0] + ": rollback: lvslb-api REMOVE /remove_ipvs/TCP/203.0.113.1/80/ returned 400",
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+	for i, v := range want {
+		if !strings.HasPrefix(diags[i].Summary, v) {
+			t.Errorf("diagnostic %d: got %q, want prefix %q", i, diags[i].Summary, v)
+		}
+	}
+}
+
+func TestResourceIpvs_externalBackends(t *testing.T) {
+	server := lvslbapitest.NewServer("", "")
+	defer server.Close()
+	client, err := lvslbapi.New(server.URL)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	resourceIpvs := lvslb.Provider().ResourcesMap["lvslb_ipvs"]
+	config := map[string]interface{}{"ip": "203.0.113.1", "port": 80, "external_backends": true}
+	diff, err := resourceIpvs.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("plan creation: %v", err)
+	}
+	state, diags := resourceIpvs.Apply(context.Background(), nil, diff, client)
+	if diags.HasError() {
+		t.Fatalf("create: %v", diags)
+	}
+	// backend added by lvslb_ipvs_backend (maybe in another workspace) after refresh of lvslb_ipvs
+	key := lvslbapi.VirtualServerKey{IP: "203.0.113.1", Protocol: lvslbapi.ProtocolTCP, Port: 80}
+	err = client.ModifyVirtualServer(context.Background(), key, func(vs *lvslbapi.VirtualServer) error {
+		vs.Backends = append(vs.Backends, lvslbapi.Backend{
+			IP: "192.0.2.1", Port: 80, Weight: 1, CheckType: lvslbapi.CheckTypeTCP,
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("add backend: %v", err)
+	}
+	config["algo"] = "rr"
+	diff, err = resourceIpvs.Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("plan change of algo: %v", err)
+	}
+	if _, diags := resourceIpvs.Apply(context.Background(), state, diff, client); diags.HasError() {
+		t.Fatalf("change algo: %v", diags)
+	}
+	vs, _ := server.VirtualServer(key)
+	if vs.LbAlgo != lvslbapi.LbAlgoRR || len(vs.Backends) != 1 || vs.Backends[0].IP != "192.0.2.1" {
+		t.Errorf("backend not kept on change of virtual server: %+v", vs)
+	}
+}
+
+func testAccCheckIpvsExists(
+	server *lvslbapitest.Server, key lvslbapi.VirtualServerKey, check func(lvslbapi.VirtualServer) error,
+) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		vs, ok := server.VirtualServer(key)
+		if !ok {
+			return fmt.Errorf("virtual server %v doesn't exist", key)
+		}
+		if check != nil {
+			return check(vs)
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckIpvsNotExists(server *lvslbapitest.Server, key lvslbapi.VirtualServerKey) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		if _, ok := server.VirtualServer(key); ok {
+			return fmt.Errorf("virtual server %v still exists", key)
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckIpvsDestroy(server *lvslbapitest.Server) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		if server.Len() != 0 {
+			return fmt.Errorf("%d virtual server(s) still exist", server.Len())
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,222 @@
+package lvslb
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const (
+	vaultKVv1 = 1
+	vaultKVv2 = 2
+
+	defaultVaultKubernetesJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	// logSubsystemVault is the name of tflog subsystem for Vault.
+	logSubsystemVault = "vault"
+)
+
+// vaultConfig to read login/password of API in Vault.
+type vaultConfig struct {
+	kvVersion         int
+	secretVersion     int
+	address           string
+	namespace         string
+	token             string
+	approleMount      string
+	approleRoleID     string
+	approleSecretID   string
+	kubernetesMount   string
+	kubernetesRole    string
+	kubernetesJWTFile string
+	jwtMount          string
+	jwtRole           string
+	jwt               string
+	mount             string
+	path              string
+	key               string
+	loginField        string
+	passwordField     string
+	approleEnable     bool
+	kubernetesEnable  bool
+	jwtEnable         bool
+}
+
+// newClient returns Vault client authenticated with token (argument or VAULT_TOKEN)
+// or with AppRole, Kubernetes or JWT auth method.
+func (v *vaultConfig) newClient(ctx context.Context) (*vaultapi.Client, error) {
+	clientConfig := vaultapi.DefaultConfig()
+	if clientConfig.Error != nil {
+		return nil, fmt.Errorf("[ERROR] read Vault environment variables: %w", clientConfig.Error)
+	}
+	if v.address != "" {
+		clientConfig.Address = v.address
+	}
+	client, err := vaultapi.NewClient(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("[ERROR] create Vault client: %w", err)
+	}
+	if v.namespace != "" {
+		client.SetNamespace(v.namespace)
+	}
+	if v.token != "" {
+		client.SetToken(v.token)
+	}
+	var loginPath string
+	var loginData map[string]interface{}
+	switch {
+	case v.approleEnable:
+		loginPath = "auth/" + strings.Trim(v.approleMount, "/") + "/login"
+		loginData = map[string]interface{}{
+			"role_id":   v.approleRoleID,
+			"secret_id": v.approleSecretID,
+		}
+	case v.kubernetesEnable:
+		jwt, err := ioutil.ReadFile(v.kubernetesJWTFile)
+		if err != nil {
+			return nil, fmt.Errorf("[ERROR] read Kubernetes service account token for Vault: %w", err)
+		}
+		loginPath = "auth/" + strings.Trim(v.kubernetesMount, "/") + "/login"
+		loginData = map[string]interface{}{
+			"role": v.kubernetesRole,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}
+	case v.jwtEnable:
+		loginPath = "auth/" + strings.Trim(v.jwtMount, "/") + "/login"
+		loginData = map[string]interface{}{
+			"role": v.jwtRole,
+			"jwt":  v.jwt,
+		}
+	default:
+		return client, nil
+	}
+	tflog.SubsystemDebug(ctx, logSubsystemVault, "login to Vault", map[string]interface{}{"path": loginPath})
+	secret, err := client.Logical().Write(loginPath, loginData)
+	if err != nil {
+		return nil, fmt.Errorf("[ERROR] login to Vault with %v: %w", loginPath, err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("[ERROR] login to Vault with %v: no token returned", loginPath)
+	}
+	client.SetToken(secret.Auth.ClientToken)
+
+	return client, nil
+}
+
+// getLogin reads login and password in Vault secret
+// <mount>/<path>/<key> (KV v1) or <mount>/data/<path>/<key> (KV v2).
+func (v *vaultConfig) getLogin(ctx context.Context) (string, string, error) {
+	ctx = v.logContext(ctx)
+	client, err := v.newClient(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	mount := strings.Trim(v.mount, "/")
+	kvVersion := v.kvVersion
+	if kvVersion == 0 {
+		kvVersion = detectVaultKVVersion(ctx, client, mount)
+	}
+	var secretPath string
+	var data map[string]interface{}
+	switch kvVersion {
+	case vaultKVv2:
+		secretPath = strings.Join([]string{mount, "data", strings.Trim(v.path, "/"), v.key}, "/")
+		var versionParam map[string][]string
+		if v.secretVersion != 0 {
+			versionParam = map[string][]string{"version": {strconv.Itoa(v.secretVersion)}}
+		}
+		secret, err := client.Logical().ReadWithData(secretPath, versionParam)
+		if err != nil {
+			return "", "", fmt.Errorf("[ERROR] read Vault secret %v: %w", secretPath, err)
+		}
+		if secret == nil || secret.Data == nil || secret.Data["data"] == nil {
+			return "", "", fmt.Errorf("[ERROR] Vault secret %v not found (KV v2)", secretPath)
+		}
+		var ok bool
+		data, ok = secret.Data["data"].(map[string]interface{})
+		if !ok {
+			return "", "", fmt.Errorf("[ERROR] unexpected data format in Vault secret %v (KV v2)", secretPath)
+		}
+	default:
+		secretPath = strings.Join([]string{mount, strings.Trim(v.path, "/"), v.key}, "/")
+		secret, err := client.Logical().Read(secretPath)
+		if err != nil {
+			return "", "", fmt.Errorf("[ERROR] read Vault secret %v: %w", secretPath, err)
+		}
+		if secret == nil || secret.Data == nil {
+			return "", "", fmt.Errorf("[ERROR] Vault secret %v not found (KV v1)", secretPath)
+		}
+		data = secret.Data
+	}
+	tflog.SubsystemDebug(ctx, logSubsystemVault, "read Vault secret",
+		map[string]interface{}{"path": secretPath, "kv_version": kvVersion})
+	missingKeys := make([]string, 0)
+	values := make(map[string]string)
+	for _, k := range []string{v.loginField, v.passwordField} {
+		value, ok := data[k]
+		if !ok || value == nil {
+			missingKeys = append(missingKeys, k)
+
+			continue
+		}
+		valueString, ok := value.(string)
+		if !ok {
+			return "", "", fmt.Errorf("[ERROR] key %v in Vault secret %v isn't a string", k, secretPath)
+		}
+		values[k] = valueString
+	}
+	if len(missingKeys) > 0 {
+		return "", "", fmt.Errorf("[ERROR] key(s) %v missing in Vault secret %v",
+			strings.Join(missingKeys, ", "), secretPath)
+	}
+
+	return values[v.loginField], values[v.passwordField], nil
+}
+
+// logContext returns context with tflog subsystem for Vault which masks tokens and credentials.
+func (v *vaultConfig) logContext(ctx context.Context) context.Context {
+	ctx = tflog.NewSubsystem(ctx, logSubsystemVault)
+	ctx = tflog.SubsystemMaskFieldValuesWithFieldKeys(ctx, logSubsystemVault,
+		"token", "secret_id", "jwt", "password")
+	secrets := make([]string, 0)
+	for _, secret := range []string{v.token, v.approleSecretID, v.jwt} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) > 0 {
+		ctx = tflog.SubsystemMaskAllFieldValuesStrings(ctx, logSubsystemVault, secrets...)
+		ctx = tflog.SubsystemMaskMessageStrings(ctx, logSubsystemVault, secrets...)
+	}
+
+	return ctx
+}
+
+// detectVaultKVVersion reads options of mount and returns version of KV secrets engine
+// (fallback to KV v1 if options can't be read like Vault CLI).
+func detectVaultKVVersion(ctx context.Context, client *vaultapi.Client, mount string) int {
+	secret, err := client.Logical().Read("sys/internal/ui/mounts/" + mount)
+	if err != nil || secret == nil || secret.Data == nil {
+		logFields := map[string]interface{}{"mount": mount}
+		if err != nil {
+			logFields["error"] = err.Error()
+		}
+		tflog.SubsystemWarn(ctx, logSubsystemVault, "can't read options of Vault mount, use KV v1", logFields)
+
+		return vaultKVv1
+	}
+	options, ok := secret.Data["options"].(map[string]interface{})
+	if !ok {
+		return vaultKVv1
+	}
+	if version, ok := options["version"].(string); ok && version == "2" {
+		return vaultKVv2
+	}
+
+	return vaultKVv1
+}
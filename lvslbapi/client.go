@@ -0,0 +1,684 @@
+// Package lvslbapi is a client for lvslb-api, the HTTP API which manages
+// keepalived virtual servers of a Linux Virtual Server load balancer.
+package lvslbapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client sends requests to lvslb-api.
+// It's safe for concurrent use by multiple goroutines.
+type Client struct {
+	rawEndpoints        []string
+	endpoints           []*url.URL
+	port                int
+	https               bool
+	logname             string
+	login               string
+	password            string
+	requestTags         map[string]string
+	logRequestBodies    bool
+	logSecrets          []string
+	logger              Logger
+	maxParallelRequests int
+	maxRetries          int
+	retryWaitMin        time.Duration
+	retryWaitMax        time.Duration
+	tlsConfig           *tls.Config
+	timeouts            Timeouts
+	httpClient          *http.Client
+	locks               *mutexKV
+	requestSem          chan struct{}
+}
+
+// Timeouts for http.Client used by Client (0 = no timeout).
+type Timeouts struct {
+	Request        time.Duration
+	Dial           time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+}
+
+const (
+	// DefaultPort is the default port of lvslb-api.
+	DefaultPort = 8080
+	// DefaultDialTimeout is the default timeout to establish connection.
+	DefaultDialTimeout = 30 * time.Second
+	// DefaultTLSHandshakeTimeout is the default timeout of TLS handshake.
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConnsPerHost = 16
+)
+
+// requestTagHeaderPrefix is the prefix of header name for each request tag.
+const requestTagHeaderPrefix = "X-Lvslb-Tag-"
+
+// New returns Client for lvslb-api configured with opts.
+// endpoint is the base URL of lvslb-api (<scheme>://<host>[:<port>][/<base path>])
+// or only its host (IP, name, <host>:<port> or [<IPv6>]:<port>) completed with WithHTTPS and WithPort.
+// Use WithPeers to apply changes on several nodes (e.g. keepalived master and backup).
+func New(endpoint string, opts ...Option) (*Client, error) {
+	if endpoint == "" {
+		return nil, errors.New("endpoint of lvslb-api is empty")
+	}
+	client := &Client{
+		rawEndpoints: []string{endpoint},
+		port:         DefaultPort,
+		timeouts: Timeouts{
+			Dial:         DefaultDialTimeout,
+			TLSHandshake: DefaultTLSHandshakeTimeout,
+		},
+		locks: newMutexKV(),
+	}
+	for _, opt := range opts {
+		if err := opt(client); err != nil {
+			return nil, err
+		}
+	}
+	if client.maxParallelRequests > 0 {
+		client.requestSem = make(chan struct{}, client.maxParallelRequests)
+	}
+	for _, host := range client.rawEndpoints {
+		endpoint, err := client.parseEndpoint(host)
+		if err != nil {
+			return nil, err
+		}
+		client.endpoints = append(client.endpoints, endpoint)
+	}
+	if client.httpClient == nil {
+		client.httpClient = newHTTPClient(client.tlsConfig, client.timeouts, client.maxParallelRequests)
+	}
+
+	return client, nil
+}
+
+// Endpoint returns the base URL of lvslb-api on the first node.
+func (client *Client) Endpoint() string {
+	return client.endpoints[0].String()
+}
+
+// Nodes returns the base URL of lvslb-api on each node.
+func (client *Client) Nodes() []string {
+	nodes := make([]string, 0, len(client.endpoints))
+	for _, endpoint := range client.endpoints {
+		nodes = append(nodes, endpoint.String())
+	}
+
+	return nodes
+}
+
+// parseEndpoint returns base URL of lvslb-api from an URL (<scheme>://<host>[:<port>][/<base path>])
+// or from a host (IP, name, <host>:<port> or [<IPv6>]:<port>) with scheme and port of Client.
+func (client *Client) parseEndpoint(raw string) (*url.URL, error) {
+	if strings.Contains(raw, "://") {
+		endpoint, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint %v: %w", raw, err)
+		}
+		if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+			return nil, fmt.Errorf("invalid endpoint %v: scheme must be http or https", raw)
+		}
+		if endpoint.Hostname() == "" {
+			return nil, fmt.Errorf("invalid endpoint %v: host is empty", raw)
+		}
+		if endpoint.User != nil || endpoint.RawQuery != "" || endpoint.Fragment != "" {
+			return nil, fmt.Errorf("invalid endpoint %v: user info, query and fragment aren't allowed", raw)
+		}
+		if port := endpoint.Port(); port != "" {
+			if _, err := strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("invalid endpoint %v: port %v isn't a number", raw, port)
+			}
+		}
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "/")
+		endpoint.RawPath = ""
+
+		return endpoint, nil
+	}
+	host, port := raw, strconv.Itoa(client.port)
+	if splitHost, splitPort, err := net.SplitHostPort(raw); err == nil {
+		host, port = splitHost, splitPort
+		if _, err := strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid host %v: port %v isn't a number", raw, port)
+		}
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" || strings.ContainsAny(host, "/?#@ ") {
+		return nil, fmt.Errorf("invalid host %v", raw)
+	}
+	scheme := "http"
+	if client.https {
+		scheme = "https"
+	}
+
+	return &url.URL{Scheme: scheme, Host: net.JoinHostPort(host, port)}, nil
+}
+
+// newHTTPClient returns http.Client with keep-alive and connection pooling to reuse connections
+// between requests.
+func newHTTPClient(tlsConfig *tls.Config, timeouts Timeouts, maxParallelRequests int) *http.Client {
+	maxIdleConnsPerHost := defaultMaxIdleConnsPerHost
+	if maxParallelRequests > 0 {
+		maxIdleConnsPerHost = maxParallelRequests
+	}
+	tr := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   timeouts.Dial,
+			KeepAlive: DefaultDialTimeout,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   timeouts.TLSHandshake,
+		ResponseHeaderTimeout: timeouts.ResponseHeader,
+		MaxIdleConns:          maxIdleConnsPerHost,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       defaultIdleConnTimeout,
+	}
+
+	return &http.Client{
+		Transport: tr,
+		Timeout:   timeouts.Request,
+	}
+}
+
+// lockVirtualServer locks virtual server identified by key and returns the function to unlock it.
+// All actions on the same virtual server are serialized between callers sharing this Client.
+func (client *Client) lockVirtualServer(key VirtualServerKey) func() {
+	keyString := key.normalize().String()
+	client.locks.Lock(keyString)
+
+	return func() {
+		client.locks.Unlock(keyString)
+	}
+}
+
+// newRequest sends request to API on node and retries on transient failures.
+func (client *Client) newRequest(ctx context.Context, node *url.URL, uri string, payload interface{}) (int, string, error) {
+	return client.newRequestConfirm(ctx, node, uri, payload, nil)
+}
+
+// newRequestConfirm sends request to API on node and retries on transient failures.
+// For non-idempotent request, confirm is called before each retry
+// and retries stop with success if it returns true (previous attempt has been applied).
+func (client *Client) newRequestConfirm(ctx context.Context, node *url.URL, uri string, payload interface{},
+	confirm func(context.Context) bool) (int, string, error) {
+	for attempt := 0; ; attempt++ {
+		statuscode, body, retryAfter, err := client.sendRequest(ctx, node, uri, payload)
+		if attempt >= client.maxRetries || !retryableResponse(ctx, statuscode, err) {
+			return statuscode, body, err
+		}
+		wait := client.retryWait(attempt, retryAfter)
+		logFields := map[string]interface{}{
+			"node":    node.String(),
+			"uri":     uri,
+			"attempt": attempt + 1,
+			"wait":    wait.String(),
+		}
+		if err != nil {
+			logFields["error"] = err.Error()
+		} else {
+			logFields["status_code"] = statuscode
+		}
+		client.logWarn(ctx, "request API failed, retry", logFields)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+
+			return http.StatusInternalServerError, "", ctx.Err()
+		}
+		if confirm != nil && confirm(ctx) {
+			client.logDebug(ctx, "request API already applied, stop retry",
+				map[string]interface{}{"node": node.String(), "uri": uri})
+
+			return http.StatusOK, "", nil
+		}
+	}
+}
+
+// retryableResponse returns true for network errors, 429 and 5xx status code.
+func retryableResponse(ctx context.Context, statuscode int, err error) bool {
+	if err != nil {
+		var urlErr *url.Error
+
+		return ctx.Err() == nil && errors.As(err, &urlErr)
+	}
+
+	return statuscode == http.StatusTooManyRequests || statuscode >= http.StatusInternalServerError
+}
+
+// retryWait returns exponential backoff with jitter between retryWaitMin and retryWaitMax
+// or the Retry-After value of response (bounded by retryWaitMax).
+func (client *Client) retryWait(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if client.retryWaitMax > 0 && retryAfter > client.retryWaitMax {
+			return client.retryWaitMax
+		}
+
+		return retryAfter
+	}
+	wait := client.retryWaitMin
+	for i := 0; i < attempt && (client.retryWaitMax <= 0 || wait < client.retryWaitMax); i++ {
+		wait *= 2
+	}
+	if client.retryWaitMax > 0 && wait > client.retryWaitMax {
+		wait = client.retryWaitMax
+	}
+	if wait <= 0 {
+		return 0
+	}
+	// equal jitter: half fixed, half random
+	half := wait / 2
+
+	return half + time.Duration(rand.Int63n(int64(wait-half)+1)) // nolint: gosec
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+
+	return 0
+}
+
+func (client *Client) sendRequest(
+	ctx context.Context, node *url.URL, uri string, payload interface{},
+) (int, string, time.Duration, error) {
+	requestURL := *node
+	requestURL.Path = node.Path + uri
+	requestURL.RawQuery = url.Values{"logname": []string{client.logname}}.Encode()
+	urlString := requestURL.String()
+	body := new(bytes.Buffer)
+	err := json.NewEncoder(body).Encode(payload)
+	if err != nil {
+		return http.StatusInternalServerError, "", 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", urlString, body)
+	if err != nil {
+		return http.StatusInternalServerError, "", 0, err
+	}
+	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+	for k, v := range client.requestTags {
+		req.Header.Set(requestTagHeaderPrefix+k, v)
+	}
+	if client.login != "" && client.password != "" {
+		req.SetBasicAuth(client.login, client.password)
+	}
+	if client.requestSem != nil {
+		select {
+		case client.requestSem <- struct{}{}:
+			defer func() { <-client.requestSem }()
+		case <-ctx.Done():
+			return http.StatusInternalServerError, "", 0, ctx.Err()
+		}
+	}
+	logFields := map[string]interface{}{
+		"url":    urlString,
+		"method": req.Method,
+	}
+	if client.logRequestBodies {
+		logFields["body"] = body.String()
+	}
+	client.logDebug(ctx, "request API", logFields)
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return http.StatusInternalServerError, "", 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return http.StatusInternalServerError, "", 0, err
+	}
+	logFields = map[string]interface{}{
+		"url":         urlString,
+		"status_code": resp.StatusCode,
+	}
+	if client.logRequestBodies {
+		logFields["body"] = string(respBody)
+	}
+	client.logDebug(ctx, "response API", logFields)
+
+	return resp.StatusCode, string(respBody), parseRetryAfter(resp.Header.Get("Retry-After")), nil
+}
+
+// ipvsURI returns URI of action (add, check, change, remove, status) for virtual server.
+func ipvsURI(action string, wire wireIpvs) string {
+	return "/" + action + "_ipvs/" + wire.Protocol + "/" + wire.IP + "/" + wire.Port + "/"
+}
+
+// ipvsExists checks with one request (without retry) that virtual server exists on node.
+func (client *Client) ipvsExists(ctx context.Context, node *url.URL, wire wireIpvs) bool {
+	statuscode, _, _, err := client.sendRequest(ctx, node, ipvsURI("check", wire), wire)
+
+	return err == nil && statuscode == http.StatusOK
+}
+
+// ipvsRemoved returns true only if node confirms that virtual server doesn't exist.
+func (client *Client) ipvsRemoved(ctx context.Context, node *url.URL, wire wireIpvs) bool {
+	statuscode, _, _, err := client.sendRequest(ctx, node, ipvsURI("check", wire), wire)
+
+	return err == nil && statuscode == http.StatusNotFound
+}
+
+func (client *Client) addNode(ctx context.Context, node *url.URL, wire wireIpvs) error {
+	uri := ipvsURI("add", wire)
+	// ADD isn't idempotent, don't retry if virtual server has been created by previous attempt
+	statuscode, body, err := client.newRequestConfirm(ctx, node, uri, wire,
+		func(ctx context.Context) bool {
+			return client.ipvsExists(ctx, node, wire)
+		})
+	if err != nil {
+		return err
+	}
+	if statuscode != http.StatusOK {
+		return newAPIError("ADD", uri, statuscode, body)
+	}
+
+	return nil
+}
+
+func (client *Client) getNode(ctx context.Context, node *url.URL, key VirtualServerKey) (*VirtualServer, error) {
+	wire := wireIpvsKey(key)
+	uri := ipvsURI("check", wire)
+	statuscode, body, err := client.newRequest(ctx, node, uri, wire)
+	if err != nil {
+		return nil, err
+	}
+	if statuscode != http.StatusOK {
+		return nil, newAPIError("CHECK", uri, statuscode, body)
+	}
+	var wireRead wireIpvs
+	if errDecode := json.Unmarshal([]byte(body), &wireRead); errDecode != nil {
+		return nil, fmt.Errorf("decode json API response (%v) %v", errDecode, body)
+	}
+	vs, err := wireRead.virtualServer()
+	if err != nil {
+		return nil, err
+	}
+
+	return &vs, nil
+}
+
+func (client *Client) changeNode(ctx context.Context, node *url.URL, wire wireIpvs) error {
+	uri := ipvsURI("change", wire)
+	statuscode, body, err := client.newRequest(ctx, node, uri, wire)
+	if err != nil {
+		return err
+	}
+	if statuscode != http.StatusOK {
+		return newAPIError("CHANGE", uri, statuscode, body)
+	}
+
+	return nil
+}
+
+func (client *Client) removeNode(ctx context.Context, node *url.URL, wire wireIpvs) error {
+	uri := ipvsURI("remove", wire)
+	// REMOVE isn't idempotent, don't retry if virtual server has been removed by previous attempt
+	statuscode, body, err := client.newRequestConfirm(ctx, node, uri, wire,
+		func(ctx context.Context) bool {
+			return client.ipvsRemoved(ctx, node, wire)
+		})
+	if err != nil {
+		return err
+	}
+	if statuscode != http.StatusOK {
+		return newAPIError("REMOVE", uri, statuscode, body)
+	}
+
+	return nil
+}
+
+// applyAllNodes calls apply on each node and returns PartialError with failed nodes
+// (or the error itself with a single node).
+func (client *Client) applyAllNodes(action string, apply func(node *url.URL) error) error {
+	partialErr := &PartialError{Action: action}
+	for _, node := range client.endpoints {
+		if err := apply(node); err != nil {
+			if len(client.endpoints) == 1 {
+				return err
+			}
+			partialErr.Failed = append(partialErr.Failed, &NodeError{Node: node.String(), Err: err})
+		} else {
+			partialErr.Applied = append(partialErr.Applied, node.String())
+		}
+	}
+	if len(partialErr.Failed) > 0 {
+		return partialErr
+	}
+
+	return nil
+}
+
+// AddVirtualServer creates virtual server on each node.
+// Failed attempts are retried only if virtual server hasn't been created by a previous attempt.
+// If it fails on a node, virtual server is removed from nodes where it has been created
+// and PartialError reports the result on each node.
+func (client *Client) AddVirtualServer(ctx context.Context, vs *VirtualServer) error {
+	defer client.lockVirtualServer(vs.Key())()
+	wire := vs.toWire()
+	for i, node := range client.endpoints {
+		err := client.addNode(ctx, node, wire)
+		if err == nil {
+			continue
+		}
+		if len(client.endpoints) == 1 {
+			return err
+		}
+		partialErr := &PartialError{
+			Action: "ADD",
+			Failed: []*NodeError{{Node: node.String(), Err: err}},
+		}
+		for _, nodeDone := range client.endpoints[:i] {
+			if errRollback := client.removeNode(ctx, nodeDone, wire); errRollback != nil {
+				partialErr.Applied = append(partialErr.Applied, nodeDone.String())
+				partialErr.Failed = append(partialErr.Failed, &NodeError{
+					Node: nodeDone.String(),
+					Err:  fmt.Errorf("rollback: %w", errRollback),
+				})
+			} else {
+				partialErr.RolledBack = append(partialErr.RolledBack, nodeDone.String())
+			}
+		}
+
+		return partialErr
+	}
+
+	return nil
+}
+
+// GetVirtualServer reads virtual server on the first node.
+// It returns an APIError matching ErrNotFound (with errors.Is) if virtual server doesn't exist.
+func (client *Client) GetVirtualServer(ctx context.Context, key VirtualServerKey) (*VirtualServer, error) {
+	defer client.lockVirtualServer(key)()
+
+	return client.getNode(ctx, client.endpoints[0], key)
+}
+
+// NodeVirtualServer is the virtual server read on a node.
+type NodeVirtualServer struct {
+	Node string
+	// VirtualServer is nil if virtual server doesn't exist on node or can't be read.
+	VirtualServer *VirtualServer
+	// Err is the error of reading virtual server on node (other than ErrNotFound).
+	Err error
+}
+
+// GetVirtualServerNodes reads virtual server on each node to compare them.
+// An error on a node is reported in its NodeVirtualServer,
+// GetVirtualServerNodes returns an error only if virtual server can't be read on any node.
+func (client *Client) GetVirtualServerNodes(ctx context.Context, key VirtualServerKey) ([]NodeVirtualServer, error) {
+	defer client.lockVirtualServer(key)()
+	nodesVS := make([]NodeVirtualServer, 0, len(client.endpoints))
+	partialErr := &PartialError{Action: "CHECK"}
+	for _, node := range client.endpoints {
+		vs, err := client.getNode(ctx, node, key)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			if len(client.endpoints) == 1 {
+				return nil, err
+			}
+			partialErr.Failed = append(partialErr.Failed, &NodeError{Node: node.String(), Err: err})
+			nodesVS = append(nodesVS, NodeVirtualServer{Node: node.String(), Err: err})
+
+			continue
+		}
+		nodesVS = append(nodesVS, NodeVirtualServer{Node: node.String(), VirtualServer: vs})
+	}
+	if len(partialErr.Failed) == len(client.endpoints) {
+		return nil, partialErr
+	}
+
+	return nodesVS, nil
+}
+
+// UpdateVirtualServer replaces configuration (including backends) of existing virtual server on each node.
+// With several nodes, virtual server is created on nodes where it doesn't exist (e.g. removed outside of client).
+// Change is applied on all nodes even if it fails on one of them, PartialError reports the result on each node.
+func (client *Client) UpdateVirtualServer(ctx context.Context, vs *VirtualServer) error {
+	defer client.lockVirtualServer(vs.Key())()
+
+	return client.updateAllNodes(ctx, vs)
+}
+
+func (client *Client) updateAllNodes(ctx context.Context, vs *VirtualServer) error {
+	wire := vs.toWire()
+
+	return client.applyAllNodes("CHANGE", func(node *url.URL) error {
+		err := client.changeNode(ctx, node, wire)
+		if errors.Is(err, ErrNotFound) && len(client.endpoints) > 1 {
+			return client.addNode(ctx, node, wire)
+		}
+
+		return err
+	})
+}
+
+// ModifyVirtualServer reads virtual server on the first node, calls modify with it
+// and replaces configuration on each node with the result (like UpdateVirtualServer).
+// Virtual server stays locked between read and update so concurrent modifications
+// with the same Client aren't lost.
+// If modify returns ErrNotModified, update is skipped and ModifyVirtualServer returns nil,
+// others errors of modify are returned as is.
+func (client *Client) ModifyVirtualServer(
+	ctx context.Context, key VirtualServerKey, modify func(vs *VirtualServer) error,
+) error {
+	defer client.lockVirtualServer(key)()
+	vs, err := client.getNode(ctx, client.endpoints[0], key)
+	if err != nil {
+		return err
+	}
+	if err := modify(vs); err != nil {
+		if errors.Is(err, ErrNotModified) {
+			return nil
+		}
+
+		return err
+	}
+	if vs.Key().normalize() != key.normalize() {
+		return fmt.Errorf("modify can't change key of virtual server %v", key)
+	}
+
+	return client.updateAllNodes(ctx, vs)
+}
+
+// RemoveVirtualServer deletes virtual server on each node.
+// Removal is applied on all nodes even if it fails on one of them, PartialError reports the result on each node.
+// With several nodes, a node where virtual server doesn't exist is considered as already removed.
+func (client *Client) RemoveVirtualServer(ctx context.Context, key VirtualServerKey) error {
+	defer client.lockVirtualServer(key)()
+	wire := wireIpvsKey(key)
+
+	return client.applyAllNodes("REMOVE", func(node *url.URL) error {
+		err := client.removeNode(ctx, node, wire)
+		if errors.Is(err, ErrNotFound) && len(client.endpoints) > 1 {
+			return nil
+		}
+
+		return err
+	})
+}
+
+// ListVirtualServers reads all virtual servers on the first node.
+// It returns ErrListNotSupported if lvslb-api doesn't have /list_ipvs/ endpoint.
+func (client *Client) ListVirtualServers(ctx context.Context) ([]VirtualServer, error) {
+	uri := "/list_ipvs/"
+	statuscode, body, err := client.newRequest(ctx, client.endpoints[0], uri, wireIpvs{})
+	if err != nil {
+		return nil, err
+	}
+	switch statuscode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrListNotSupported
+	default:
+		return nil, newAPIError("LIST", uri, statuscode, body)
+	}
+	var wireList []wireIpvs
+	if errDecode := json.Unmarshal([]byte(body), &wireList); errDecode != nil {
+		return nil, fmt.Errorf("decode json API response (%v) %v", errDecode, body)
+	}
+	list := make([]VirtualServer, 0, len(wireList))
+	for _, v := range wireList {
+		vs, err := v.virtualServer()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, vs)
+	}
+
+	return list, nil
+}
+
+// GetVirtualServerStatus reads runtime state of backends of virtual server on the first node.
+// It returns an APIError matching ErrNotFound (with errors.Is) if virtual server doesn't exist
+// and ErrStatusNotSupported if lvslb-api doesn't have /status_ipvs/ endpoint.
+func (client *Client) GetVirtualServerStatus(ctx context.Context, key VirtualServerKey) (*VirtualServerStatus, error) {
+	wire := wireIpvsKey(key)
+	uri := ipvsURI("status", wire)
+	statuscode, body, err := client.newRequest(ctx, client.endpoints[0], uri, wire)
+	if err != nil {
+		return nil, err
+	}
+	if statuscode == http.StatusNotFound {
+		// lvslb-api without /status_ipvs/ endpoint also returns 404, check if virtual server exists
+		_, errCheck := client.getNode(ctx, client.endpoints[0], key)
+		if errCheck == nil {
+			return nil, ErrStatusNotSupported
+		}
+		if !errors.Is(errCheck, ErrNotFound) {
+			return nil, errCheck
+		}
+	}
+	if statuscode != http.StatusOK {
+		return nil, newAPIError("STATUS", uri, statuscode, body)
+	}
+	var wireRead wireStatus
+	if errDecode := json.Unmarshal([]byte(body), &wireRead); errDecode != nil {
+		return nil, fmt.Errorf("decode json API response (%v) %v", errDecode, body)
+	}
+	status, err := wireRead.virtualServerStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
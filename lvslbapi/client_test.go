@@ -0,0 +1,478 @@
+package lvslbapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi/lvslbapitest"
+)
+
+func TestClient_typedWire(t *testing.T) {
+	var addBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/add_ipvs/TCP/203.0.113.1/80/":
+			if err := json.NewDecoder(r.Body).Decode(&addBody); err != nil {
+				t.Errorf("decode add body: %v", err)
+			}
+		case "/check_ipvs/TCP/203.0.113.1/80/":
+			_, _ = w.Write([]byte(`{"IP":"203.0.113.1","Port":"80","Protocol":"TCP","Delay_loop":"5",` +
+				`"Lb_algo":"wlc","Lb_kind":"NAT","Persistence_timeout":"0","Sorry_port":"0",` +
+				`"Backends":[{"IP":"192.0.2.1","Port":"8080","Weight":"1","Check_type":"HTTP_GET",` +
+				`"Check_port":"8080","Url_status_code":"200"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+	client, err := lvslbapi.New(serverURL.Hostname(), lvslbapi.WithPort(port))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	vs := lvslbapi.VirtualServer{
+		IP:        "203.0.113.1",
+		Port:      80,
+		Protocol:  lvslbapi.ProtocolTCP,
+		DelayLoop: 5,
+		LbAlgo:    lvslbapi.LbAlgoWLC,
+		LbKind:    lvslbapi.LbKindNAT,
+		Backends:  []lvslbapi.Backend{{IP: "192.0.2.1", Port: 8080, Weight: 1, CheckType: lvslbapi.CheckTypeTCP}},
+	}
+	if err := client.AddVirtualServer(context.Background(), &vs); err != nil {
+		t.Fatalf("add virtual server: %v", err)
+	}
+	if addBody["Port"] != "80" || addBody["Delay_loop"] != "5" {
+		t.Errorf("integers not sent as strings: %v", addBody)
+	}
+	vsRead, err := client.GetVirtualServer(context.Background(), vs.Key())
+	if err != nil {
+		t.Fatalf("get virtual server: %v", err)
+	}
+	if vsRead.Port != 80 || len(vsRead.Backends) != 1 || vsRead.Backends[0].URLStatusCode != http.StatusOK ||
+		vsRead.Backends[0].CheckType != lvslbapi.CheckTypeHTTP {
+		t.Errorf("unexpected virtual server read: %+v", vsRead)
+	}
+}
+
+func TestClient_addRollback(t *testing.T) {
+	removed := false
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/remove_ipvs/TCP/203.0.113.1/80/" {
+			removed = true
+		}
+	}))
+	defer master.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backup.Close()
+	masterURL, _ := url.Parse(master.URL)
+	backupURL, _ := url.Parse(backup.URL)
+	client, err := lvslbapi.New(masterURL.Host, lvslbapi.WithPeers(backupURL.Host))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	err = client.AddVirtualServer(context.Background(), &lvslbapi.VirtualServer{
+		IP:       "203.0.113.1",
+		Port:     80,
+		Protocol: lvslbapi.ProtocolTCP,
+	})
+	var partialErr *lvslbapi.PartialError
+	if !errors.As(err, &partialErr) {
+		t.Fatalf("expected PartialError, got %v", err)
+	}
+	if len(partialErr.Failed) != 1 || partialErr.Failed[0].Node != backup.URL {
+		t.Errorf("unexpected failed nodes: %v", partialErr)
+	}
+	if !removed || len(partialErr.RolledBack) != 1 || len(partialErr.Applied) != 0 {
+		t.Errorf("virtual server not rolled back on first node: %v", partialErr)
+	}
+}
+
+func TestClient_fakeServer(t *testing.T) {
+	server := lvslbapitest.NewServer("user", "pass")
+	defer server.Close()
+	client, err := lvslbapi.New(server.URL, lvslbapi.WithBasicAuth("user", "pass"), lvslbapi.WithRetry(1, 0, 0))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+	vs := lvslbapi.VirtualServer{
+		IP:       "203.0.113.1",
+		Port:     80,
+		Protocol: lvslbapi.ProtocolTCP,
+		LbAlgo:   lvslbapi.LbAlgoWLC,
+		Backends: []lvslbapi.Backend{{IP: "192.0.2.1", Port: 80, Weight: 1, CheckType: lvslbapi.CheckTypeTCP}},
+	}
+	server.InjectFault(lvslbapitest.ActionAdd, http.StatusServiceUnavailable, 1)
+	if err := client.AddVirtualServer(ctx, &vs); err != nil {
+		t.Fatalf("add virtual server: %v", err)
+	}
+	if server.Requests(lvslbapitest.ActionAdd) != 2 {
+		t.Errorf("transient fault not retried: %v ADD requests", server.Requests(lvslbapitest.ActionAdd))
+	}
+	if err := client.AddVirtualServer(ctx, &vs); !errors.Is(err, lvslbapi.ErrConflict) {
+		t.Errorf("expected ErrConflict on second ADD, got %v", err)
+	}
+	vs.LbAlgo = lvslbapi.LbAlgoRR
+	if err := client.UpdateVirtualServer(ctx, &vs); err != nil {
+		t.Fatalf("update virtual server: %v", err)
+	}
+	vsRead, err := client.GetVirtualServer(ctx, vs.Key())
+	if err != nil {
+		t.Fatalf("get virtual server: %v", err)
+	}
+	if !vsRead.Equal(&vs) {
+		t.Errorf("unexpected virtual server read: got %+v, want %+v", vsRead, vs)
+	}
+	list, err := client.ListVirtualServers(ctx)
+	if err != nil || len(list) != 1 {
+		t.Errorf("unexpected list: %v, %v", list, err)
+	}
+	if err := client.RemoveVirtualServer(ctx, vs.Key()); err != nil {
+		t.Fatalf("remove virtual server: %v", err)
+	}
+	if _, err := client.GetVirtualServer(ctx, vs.Key()); !errors.Is(err, lvslbapi.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after REMOVE, got %v", err)
+	}
+	unauthorized, _ := lvslbapi.New(server.URL)
+	if _, err := unauthorized.GetVirtualServer(ctx, vs.Key()); !errors.Is(err, lvslbapi.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized without credentials, got %v", err)
+	}
+}
+
+func TestClient_nodes(t *testing.T) {
+	master := lvslbapitest.NewServer("", "")
+	defer master.Close()
+	backup := lvslbapitest.NewServer("", "")
+	defer backup.Close()
+	client, err := lvslbapi.New(master.URL, lvslbapi.WithPeers(backup.URL), lvslbapi.WithRetry(0, 0, 0))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+	vs := lvslbapi.VirtualServer{IP: "203.0.113.1", Port: 80, Protocol: lvslbapi.ProtocolTCP, LbAlgo: lvslbapi.LbAlgoWLC}
+	if err := client.AddVirtualServer(ctx, &vs); err != nil {
+		t.Fatalf("add virtual server: %v", err)
+	}
+	backup.DeleteVirtualServer(vs.Key())
+	nodesVS, err := client.GetVirtualServerNodes(ctx, vs.Key())
+	if err != nil {
+		t.Fatalf("get virtual server on nodes: %v", err)
+	}
+	if len(nodesVS) != 2 || nodesVS[0].VirtualServer == nil || nodesVS[1].VirtualServer != nil || nodesVS[1].Err != nil {
+		t.Errorf("unexpected virtual server on nodes: %+v", nodesVS)
+	}
+	// update re-creates virtual server on node where it doesn't exist
+	vs.LbAlgo = lvslbapi.LbAlgoRR
+	if err := client.UpdateVirtualServer(ctx, &vs); err != nil {
+		t.Fatalf("update virtual server: %v", err)
+	}
+	if vsBackup, ok := backup.VirtualServer(vs.Key()); !ok || vsBackup.LbAlgo != lvslbapi.LbAlgoRR {
+		t.Errorf("virtual server not re-created on backup: %+v", vsBackup)
+	}
+	// a node which can't be read is reported without failing
+	backup.InjectFault(lvslbapitest.ActionCheck, http.StatusInternalServerError, 1)
+	nodesVS, err = client.GetVirtualServerNodes(ctx, vs.Key())
+	if err != nil {
+		t.Fatalf("get virtual server on nodes with backup failing: %v", err)
+	}
+	if len(nodesVS) != 2 || nodesVS[0].VirtualServer == nil || nodesVS[1].Err == nil {
+		t.Errorf("unexpected virtual server on nodes with backup failing: %+v", nodesVS)
+	}
+	master.InjectFault(lvslbapitest.ActionCheck, http.StatusInternalServerError, 1)
+	backup.InjectFault(lvslbapitest.ActionCheck, http.StatusInternalServerError, 1)
+	var partialErr *lvslbapi.PartialError
+	if _, err := client.GetVirtualServerNodes(ctx, vs.Key()); !errors.As(err, &partialErr) {
+		t.Errorf("expected PartialError when all nodes fail, got %v", err)
+	}
+}
+
+func TestClient_removeMissingNode(t *testing.T) {
+	master := lvslbapitest.NewServer("", "")
+	defer master.Close()
+	backup := lvslbapitest.NewServer("", "")
+	defer backup.Close()
+	client, err := lvslbapi.New(master.URL, lvslbapi.WithPeers(backup.URL), lvslbapi.WithRetry(0, 0, 0))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+	vs := lvslbapi.VirtualServer{IP: "203.0.113.1", Port: 80, Protocol: lvslbapi.ProtocolTCP}
+	// virtual server already missing on backup
+	master.SetVirtualServer(vs)
+	if err := client.RemoveVirtualServer(ctx, vs.Key()); err != nil {
+		t.Fatalf("remove virtual server missing on backup: %v", err)
+	}
+	if master.Len() != 0 {
+		t.Errorf("virtual server not removed on master")
+	}
+	// others errors still fail
+	master.SetVirtualServer(vs)
+	backup.SetVirtualServer(vs)
+	backup.InjectFault(lvslbapitest.ActionRemove, http.StatusInternalServerError, 1)
+	var partialErr *lvslbapi.PartialError
+	if err := client.RemoveVirtualServer(ctx, vs.Key()); !errors.As(err, &partialErr) || len(partialErr.Failed) != 1 {
+		t.Errorf("expected PartialError with backup failed, got %v", err)
+	}
+}
+
+func TestClient_removeRetryApplied(t *testing.T) {
+	fake := lvslbapitest.NewServer("", "")
+	defer fake.Close()
+	vs := lvslbapi.VirtualServer{IP: "203.0.113.1", Port: 80, Protocol: lvslbapi.ProtocolTCP}
+	fake.SetVirtualServer(vs)
+	// first REMOVE is applied but its response is lost in a 503
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/remove_ipvs/TCP/203.0.113.1/80/" && !failed {
+			failed = true
+			fake.Config.Handler.ServeHTTP(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+		fake.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	client, err := lvslbapi.New(server.URL, lvslbapi.WithRetry(2, 0, 0))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if err := client.RemoveVirtualServer(context.Background(), vs.Key()); err != nil {
+		t.Fatalf("remove virtual server: %v", err)
+	}
+	if fake.Requests(lvslbapitest.ActionRemove) != 1 {
+		t.Errorf("REMOVE retried after being applied: %v REMOVE requests", fake.Requests(lvslbapitest.ActionRemove))
+	}
+}
+
+func TestClient_modifyConcurrent(t *testing.T) {
+	server := lvslbapitest.NewServer("", "")
+	defer server.Close()
+	client, err := lvslbapi.New(server.URL)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+	key := lvslbapi.VirtualServerKey{IP: "203.0.113.1", Protocol: lvslbapi.ProtocolTCP, Port: 80}
+	err = client.AddVirtualServer(ctx, &lvslbapi.VirtualServer{IP: key.IP, Port: key.Port, Protocol: key.Protocol})
+	if err != nil {
+		t.Fatalf("add virtual server: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := client.ModifyVirtualServer(ctx, key, func(vs *lvslbapi.VirtualServer) error {
+				vs.Backends = append(vs.Backends, lvslbapi.Backend{IP: "192.0.2." + strconv.Itoa(i), Port: 80, Weight: 1})
+
+				return nil
+			})
+			if err != nil {
+				t.Errorf("modify virtual server: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if vs, _ := server.VirtualServer(key); len(vs.Backends) != 20 {
+		t.Errorf("concurrent modifications lost: %d backends, want 20", len(vs.Backends))
+	}
+	updates := server.Requests(lvslbapitest.ActionChange)
+	if err := client.ModifyVirtualServer(ctx, key, func(vs *lvslbapi.VirtualServer) error {
+		return lvslbapi.ErrNotModified
+	}); err != nil || server.Requests(lvslbapitest.ActionChange) != updates {
+		t.Errorf("ErrNotModified doesn't skip update: %v", err)
+	}
+}
+
+type recordLogger struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (l *recordLogger) Debug(_ context.Context, msg string, fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, fmt.Sprintf("DEBUG %s %v", msg, fields))
+}
+
+func (l *recordLogger) Warn(_ context.Context, msg string, fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, fmt.Sprintf("WARN %s %v", msg, fields))
+}
+
+func TestClient_logger(t *testing.T) {
+	server := lvslbapitest.NewServer("user", "secret")
+	defer server.Close()
+	logger := &recordLogger{}
+	client, err := lvslbapi.New(server.URL,
+		lvslbapi.WithBasicAuth("user", "secret"),
+		lvslbapi.WithLogname("audit-secret-name"),
+		lvslbapi.WithLogSecrets("audit-secret-name"),
+		lvslbapi.WithLogRequestBodies(true),
+		lvslbapi.WithRetry(1, 0, 0),
+		lvslbapi.WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	server.InjectFault(lvslbapitest.ActionAdd, http.StatusServiceUnavailable, 1)
+	err = client.AddVirtualServer(context.Background(), &lvslbapi.VirtualServer{
+		IP:       "203.0.113.1",
+		Port:     80,
+		Protocol: lvslbapi.ProtocolTCP,
+	})
+	if err != nil {
+		t.Fatalf("add virtual server: %v", err)
+	}
+	logs := strings.Join(logger.logs, "\n")
+	if !strings.Contains(logs, "WARN request API failed, retry") || !strings.Contains(logs, "DEBUG response API") {
+		t.Errorf("missing logs: %v", logs)
+	}
+	if strings.Contains(logs, "secret") || !strings.Contains(logs, "logname=***") {
+		t.Errorf("secrets not masked in logs: %v", logs)
+	}
+}
+
+func TestNew_endpoint(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		opts     []lvslbapi.Option
+		want     string
+	}{
+		{"192.0.2.1", nil, "http://192.0.2.1:8080"},
+		{"2001:db8::1", []lvslbapi.Option{lvslbapi.WithHTTPS(true), lvslbapi.WithPort(9443)}, "https://[2001:db8::1]:9443"},
+		{"[2001:db8::1]:8443", nil, "http://[2001:db8::1]:8443"},
+		{"https://lb.example.com/lvs/", nil, "https://lb.example.com/lvs"},
+		{"http://[2001:db8::1]:8080", nil, "http://[2001:db8::1]:8080"},
+	}
+	for _, c := range cases {
+		client, err := lvslbapi.New(c.endpoint, c.opts...)
+		if err != nil {
+			t.Errorf("new client with %v: %v", c.endpoint, err)
+
+			continue
+		}
+		if client.Endpoint() != c.want {
+			t.Errorf("endpoint of %v: got %v, want %v", c.endpoint, client.Endpoint(), c.want)
+		}
+	}
+	for _, endpoint := range []string{"ftp://192.0.2.1", "http:///lvs", "http://192.0.2.1:port", "https://lb/?a=b"} {
+		if _, err := lvslbapi.New(endpoint); err == nil {
+			t.Errorf("invalid endpoint %v accepted", endpoint)
+		}
+	}
+}
+
+// waitLogger records waits before retries logged by Client.
+type waitLogger struct {
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (l *waitLogger) Debug(context.Context, string, map[string]interface{}) {}
+
+func (l *waitLogger) Warn(_ context.Context, _ string, fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if wait, err := time.ParseDuration(fmt.Sprint(fields["wait"])); err == nil {
+		l.waits = append(l.waits, wait)
+	}
+}
+
+func TestClient_retryAfter(t *testing.T) {
+	var retryAfter string
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		if len(requests) == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	key := lvslbapi.VirtualServerKey{IP: "203.0.113.1", Protocol: lvslbapi.ProtocolTCP, Port: 80}
+	cases := []struct {
+		retryAfter string
+		waitMax    time.Duration
+		want       time.Duration
+	}{
+		// Retry-After is honored instead of backoff
+		{"1", 2 * time.Second, time.Second},
+		// Retry-After is bounded by max wait
+		{"120", 50 * time.Millisecond, 50 * time.Millisecond},
+	}
+	for i, c := range cases {
+		retryAfter = c.retryAfter
+		requests = nil
+		logger := &waitLogger{}
+		client, err := lvslbapi.New(server.URL,
+			lvslbapi.WithRetry(1, 10*time.Millisecond, c.waitMax), lvslbapi.WithLogger(logger))
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		if _, err := client.GetVirtualServer(context.Background(), key); !errors.Is(err, lvslbapi.ErrNotFound) {
+			t.Fatalf("case %d: expected ErrNotFound after retry, got %v", i, err)
+		}
+		if len(logger.waits) != 1 || logger.waits[0] != c.want {
+			t.Errorf("case %d: unexpected waits %v, want %v", i, logger.waits, c.want)
+		}
+		if len(requests) != 2 || requests[1].Sub(requests[0]) < c.want {
+			t.Errorf("case %d: retry sent before %v", i, c.want)
+		}
+	}
+}
+
+func TestClient_retryBackoff(t *testing.T) {
+	server := lvslbapitest.NewServer("", "")
+	defer server.Close()
+	logger := &waitLogger{}
+	client, err := lvslbapi.New(server.URL,
+		lvslbapi.WithRetry(4, 20*time.Millisecond, 50*time.Millisecond), lvslbapi.WithLogger(logger))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	server.InjectFault(lvslbapitest.ActionCheck, http.StatusServiceUnavailable, 5)
+	_, err = client.GetVirtualServer(context.Background(),
+		lvslbapi.VirtualServerKey{IP: "203.0.113.1", Protocol: lvslbapi.ProtocolTCP, Port: 80})
+	if err == nil {
+		t.Fatalf("expected error after all retries")
+	}
+	// min doubled after each retry with equal jitter (between half and full wait), bounded by max
+	bounds := [][2]time.Duration{
+		{10 * time.Millisecond, 20 * time.Millisecond},
+		{20 * time.Millisecond, 40 * time.Millisecond},
+		{25 * time.Millisecond, 50 * time.Millisecond},
+		{25 * time.Millisecond, 50 * time.Millisecond},
+	}
+	if len(logger.waits) != len(bounds) {
+		t.Fatalf("unexpected waits %v", logger.waits)
+	}
+	for i, wait := range logger.waits {
+		if wait < bounds[i][0] || wait > bounds[i][1] {
+			t.Errorf("retry %d: wait %v out of [%v, %v]", i+1, wait, bounds[i][0], bounds[i][1])
+		}
+	}
+	if _, err := lvslbapi.New(server.URL, lvslbapi.WithRetry(1, time.Second, time.Millisecond)); err == nil {
+		t.Errorf("expected error with min wait greater than max wait")
+	}
+}
@@ -0,0 +1,141 @@
+package lvslbapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNotFound matches APIError with status code 404.
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized matches APIError with status code 401 or 403.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrConflict matches APIError with status code 409.
+	ErrConflict = errors.New("conflict")
+	// ErrListNotSupported is returned by ListVirtualServers when lvslb-api doesn't have list endpoint.
+	ErrListNotSupported = errors.New("lvslb-api doesn't support listing virtual servers")
+	// ErrStatusNotSupported is returned by GetVirtualServerStatus when lvslb-api doesn't have status endpoint.
+	ErrStatusNotSupported = errors.New("lvslb-api doesn't support status of backends")
+	// ErrNotModified can be returned by the modify function of ModifyVirtualServer to skip update.
+	ErrNotModified = errors.New("virtual server not modified")
+)
+
+// APIError is returned when API responds with an unexpected status code.
+type APIError struct {
+	Action     string
+	URI        string
+	StatusCode int
+	// Message is the error message decoded from response payload (or response body).
+	Message string
+	// Body is the raw response body.
+	Body string
+}
+
+func newAPIError(action, uri string, statusCode int, body string) *APIError {
+	return &APIError{
+		Action:     action,
+		URI:        uri,
+		StatusCode: statusCode,
+		Message:    decodeAPIErrorMessage(body),
+		Body:       body,
+	}
+}
+
+// Error implements error interface.
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return e.Summary()
+	}
+
+	return e.Summary() + ": " + e.Message
+}
+
+// Summary returns action, URI and status of error without message.
+func (e *APIError) Summary() string {
+	return fmt.Sprintf("lvslb-api %v %v returned %v %v", e.Action, e.URI, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Is allows errors.Is with ErrNotFound, ErrUnauthorized and ErrConflict.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	}
+
+	return false
+}
+
+// decodeAPIErrorMessage extracts message of a JSON error payload
+// ({"error": "..."} or {"message": "..."}) or returns trimmed body.
+func decodeAPIErrorMessage(body string) string {
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &payload); err == nil {
+		for _, key := range []string{"error", "Error", "message", "Message"} {
+			if v, ok := payload[key].(string); ok && v != "" {
+				return v
+			}
+		}
+	}
+
+	return strings.TrimSpace(body)
+}
+
+// NodeError is the error of a request on one node.
+type NodeError struct {
+	Node string
+	Err  error
+}
+
+// Error implements error interface.
+func (e *NodeError) Error() string {
+	return "node " + e.Node + ": " + e.Err.Error()
+}
+
+// Unwrap allows errors.Is and errors.As with the error of request.
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// PartialError is returned when a change fails on some nodes.
+type PartialError struct {
+	Action string
+	// Failed lists errors by node (including failed rollbacks).
+	Failed []*NodeError
+	// Applied lists nodes where change has been applied (and not rolled back).
+	Applied []string
+	// RolledBack lists nodes where change has been applied then reverted.
+	RolledBack []string
+}
+
+// Error implements error interface.
+func (e *PartialError) Error() string {
+	failed := make([]string, 0, len(e.Failed))
+	for _, v := range e.Failed {
+		failed = append(failed, v.Error())
+	}
+	msg := fmt.Sprintf("lvslb-api %v failed on %d node(s): %v", e.Action, len(e.Failed), strings.Join(failed, "; "))
+	if len(e.Applied) > 0 {
+		msg += fmt.Sprintf(" (applied on %v)", strings.Join(e.Applied, ", "))
+	}
+	if len(e.RolledBack) > 0 {
+		msg += fmt.Sprintf(" (rolled back on %v)", strings.Join(e.RolledBack, ", "))
+	}
+
+	return msg
+}
+
+// Unwrap returns error of the first failed node.
+func (e *PartialError) Unwrap() error {
+	if len(e.Failed) == 0 {
+		return nil
+	}
+
+	return e.Failed[0]
+}
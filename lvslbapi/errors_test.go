@@ -0,0 +1,39 @@
+package lvslbapi_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+)
+
+func TestAPIError_Is(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &lvslbapi.APIError{StatusCode: http.StatusNotFound})
+	if !errors.Is(err, lvslbapi.ErrNotFound) {
+		t.Errorf("404 APIError isn't ErrNotFound")
+	}
+	if errors.Is(err, lvslbapi.ErrUnauthorized) || errors.Is(err, lvslbapi.ErrConflict) {
+		t.Errorf("404 APIError matches another error")
+	}
+	if !errors.Is(&lvslbapi.APIError{StatusCode: http.StatusForbidden}, lvslbapi.ErrUnauthorized) {
+		t.Errorf("403 APIError isn't ErrUnauthorized")
+	}
+	if !errors.Is(&lvslbapi.APIError{StatusCode: http.StatusConflict}, lvslbapi.ErrConflict) {
+		t.Errorf("409 APIError isn't ErrConflict")
+	}
+}
+
+func TestAPIError_Error(t *testing.T) {
+	err := &lvslbapi.APIError{
+		Action:     "CHANGE",
+		URI:        "/change_ipvs/TCP/203.0.113.1/80/",
+		StatusCode: http.StatusInternalServerError,
+		Message:    "100% failed",
+	}
+	want := "lvslb-api CHANGE /change_ipvs/TCP/203.0.113.1/80/ returned 500 Internal Server Error: 100% failed"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+}
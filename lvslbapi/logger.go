@@ -0,0 +1,75 @@
+package lvslbapi
+
+import (
+	"context"
+	"encoding/base64"
+	"sort"
+	"strings"
+)
+
+// Logger receives logs of Client (see WithLogger).
+// fields are key/value pairs describing the event, secrets are already masked in msg and fields.
+type Logger interface {
+	Debug(ctx context.Context, msg string, fields map[string]interface{})
+	Warn(ctx context.Context, msg string, fields map[string]interface{})
+}
+
+// maskedValue replaces secrets in logs.
+const maskedValue = "***"
+
+// logDebug sends debug log to logger of Client (if set) with secrets masked.
+func (client *Client) logDebug(ctx context.Context, msg string, fields map[string]interface{}) {
+	if client.logger == nil {
+		return
+	}
+	msg, fields = client.maskLog(msg, fields)
+	client.logger.Debug(ctx, msg, fields)
+}
+
+// logWarn sends warning log to logger of Client (if set) with secrets masked.
+func (client *Client) logWarn(ctx context.Context, msg string, fields map[string]interface{}) {
+	if client.logger == nil {
+		return
+	}
+	msg, fields = client.maskLog(msg, fields)
+	client.logger.Warn(ctx, msg, fields)
+}
+
+// maskLog masks values of fields authorization and password,
+// password, Authorization header and others secrets (see WithLogSecrets) in msg and string values of fields.
+func (client *Client) maskLog(msg string, fields map[string]interface{}) (string, map[string]interface{}) {
+	secrets := make([]string, 0, len(client.logSecrets)+2)
+	for _, v := range client.logSecrets {
+		if v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+	if client.password != "" {
+		secrets = append(secrets, client.password,
+			base64.StdEncoding.EncodeToString([]byte(client.login+":"+client.password)))
+	}
+	// mask longest secrets first when a secret contains another
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	oldnew := make([]string, 0, 2*len(secrets))
+	for _, v := range secrets {
+		oldnew = append(oldnew, v, maskedValue)
+	}
+	replacer := strings.NewReplacer(oldnew...)
+	masked := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		switch value := v.(type) {
+		case string:
+			if k == "authorization" || k == "password" {
+				masked[k] = maskedValue
+			} else {
+				masked[k] = replacer.Replace(value)
+			}
+		default:
+			masked[k] = v
+		}
+	}
+
+	return replacer.Replace(msg), masked
+}
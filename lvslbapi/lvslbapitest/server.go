@@ -0,0 +1,267 @@
+// Package lvslbapitest provides an in-memory lvslb-api server for tests.
+package lvslbapitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jeremmfr/terraform-provider-lvslb/lvslbapi"
+)
+
+// Actions of lvslb-api handled by Server.
+const (
+	ActionAdd    = "add"
+	ActionCheck  = "check"
+	ActionChange = "change"
+	ActionRemove = "remove"
+	ActionList   = "list"
+	ActionStatus = "status"
+)
+
+// Server is a fake lvslb-api backed by an in-memory store of virtual servers.
+type Server struct {
+	*httptest.Server
+
+	login    string
+	password string
+
+	mu       sync.Mutex
+	store    map[string]lvslbapi.VirtualServer
+	faults   map[string][]int
+	requests map[string]int
+	// status endpoint isn't supported by default like lvslb-api
+	status      bool
+	deadBackend map[string]bool
+}
+
+// NewServer starts and returns a new Server.
+// If login is not empty, requests without this login and password are rejected with 401.
+// The caller should call Close when finished, to shut it down.
+func NewServer(login, password string) *Server {
+	server := &Server{
+		login:    login,
+		password: password,
+		store:    make(map[string]lvslbapi.VirtualServer),
+		faults:   make(map[string][]int),
+		requests: make(map[string]int),
+
+		deadBackend: make(map[string]bool),
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+
+	return server
+}
+
+// InjectFault makes the next count requests of action fail with statusCode.
+func (s *Server) InjectFault(action string, statusCode, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.faults[action] = append(s.faults[action], statusCode)
+	}
+}
+
+// Requests returns the number of requests received for action (including failed requests).
+func (s *Server) Requests(action string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[action]
+}
+
+// EnableStatus makes Server respond to /status_ipvs/ with backends of virtual server in store
+// (alive unless set with SetBackendAlive, without connections).
+// Without it, /status_ipvs/ returns 404 like lvslb-api.
+func (s *Server) EnableStatus() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = true
+}
+
+// SetBackendAlive sets the state of backend ip:port returned by /status_ipvs/ on all virtual servers.
+func (s *Server) SetBackendAlive(ip string, port int, alive bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadBackend[ip+":"+strconv.Itoa(port)] = !alive
+}
+
+// VirtualServer returns virtual server in store.
+func (s *Server) VirtualServer(key lvslbapi.VirtualServerKey) (lvslbapi.VirtualServer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vs, ok := s.store[storeKey(key)]
+
+	return vs, ok
+}
+
+// SetVirtualServer adds or replaces virtual server in store (e.g. to simulate a change outside Terraform).
+func (s *Server) SetVirtualServer(vs lvslbapi.VirtualServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store[storeKey(vs.Key())] = vs
+}
+
+// DeleteVirtualServer removes virtual server from store.
+func (s *Server) DeleteVirtualServer(key lvslbapi.VirtualServerKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.store, storeKey(key))
+}
+
+// Len returns the number of virtual servers in store.
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.store)
+}
+
+func storeKey(key lvslbapi.VirtualServerKey) string {
+	key.Protocol = lvslbapi.Protocol(strings.ToUpper(string(key.Protocol)))
+
+	return key.String()
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	// /<action>_ipvs/<protocol>/<ip>/<port>/ or /list_ipvs/
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	action := strings.TrimSuffix(path[0], "_ipvs")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[action]++
+	if s.login != "" {
+		if login, password, ok := r.BasicAuth(); !ok || login != s.login || password != s.password {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+
+			return
+		}
+	}
+	if faults := s.faults[action]; len(faults) > 0 {
+		s.faults[action] = faults[1:]
+		writeError(w, faults[0], "injected fault")
+
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+
+		return
+	}
+	if action == ActionList && len(path) == 1 {
+		s.handleList(w)
+
+		return
+	}
+	if len(path) != 4 || !strings.HasSuffix(path[0], "_ipvs") {
+		writeError(w, http.StatusNotFound, "not found")
+
+		return
+	}
+	port, err := strconv.Atoi(path[3])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid port")
+
+		return
+	}
+	key := lvslbapi.VirtualServerKey{IP: path[2], Protocol: lvslbapi.Protocol(path[1]), Port: port}
+	vs, exists := s.store[storeKey(key)]
+	switch action {
+	case ActionCheck:
+		if !exists {
+			writeError(w, http.StatusNotFound, "virtual server not found")
+
+			return
+		}
+		writeJSON(w, vs)
+	case ActionAdd, ActionChange:
+		if action == ActionAdd && exists {
+			writeError(w, http.StatusConflict, "virtual server already exists")
+
+			return
+		}
+		if action == ActionChange && !exists {
+			writeError(w, http.StatusNotFound, "virtual server not found")
+
+			return
+		}
+		var vsReceived lvslbapi.VirtualServer
+		if err := json.NewDecoder(r.Body).Decode(&vsReceived); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+
+			return
+		}
+		if vsReceived.Key() != key {
+			writeError(w, http.StatusBadRequest, "virtual server in body doesn't match URL")
+
+			return
+		}
+		s.store[storeKey(key)] = vsReceived
+	case ActionRemove:
+		if !exists {
+			writeError(w, http.StatusNotFound, "virtual server not found")
+
+			return
+		}
+		delete(s.store, storeKey(key))
+	case ActionStatus:
+		if !s.status || !exists {
+			writeError(w, http.StatusNotFound, "not found")
+
+			return
+		}
+		s.handleStatus(w, vs)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) handleList(w http.ResponseWriter) {
+	keys := make([]string, 0, len(s.store))
+	for k := range s.store {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]lvslbapi.VirtualServer, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, s.store[k])
+	}
+	writeJSON(w, list)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, vs lvslbapi.VirtualServer) {
+	backends := make([]map[string]string, 0, len(vs.Backends))
+	for _, v := range vs.Backends {
+		alive := !s.deadBackend[v.IP+":"+strconv.Itoa(v.Port)]
+		backends = append(backends, map[string]string{
+			"IP":            v.IP,
+			"Port":          strconv.Itoa(v.Port),
+			"Alive":         strconv.FormatBool(alive),
+			"Weight":        strconv.Itoa(v.Weight),
+			"Active_conn":   "0",
+			"Inactive_conn": "0",
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"IP":       vs.IP,
+		"Port":     strconv.Itoa(vs.Port),
+		"Protocol": strings.ToUpper(string(vs.Protocol)),
+		"Backends": backends,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,160 @@
+package lvslbapi
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Option configures Client in New.
+type Option func(*Client) error
+
+// WithPeers adds nodes (base URL or host, same format as endpoint of New) where changes are applied
+// in addition to the node of New (e.g. backup of keepalived pair).
+func WithPeers(endpoints ...string) Option {
+	return func(client *Client) error {
+		for _, endpoint := range endpoints {
+			if endpoint == "" {
+				return errors.New("endpoint of lvslb-api peer is empty")
+			}
+			client.rawEndpoints = append(client.rawEndpoints, endpoint)
+		}
+
+		return nil
+	}
+}
+
+// WithPort sets port of lvslb-api when endpoint is a host without port (default DefaultPort).
+func WithPort(port int) Option {
+	return func(client *Client) error {
+		if port < 1 || port > 65535 {
+			return errors.New("port of lvslb-api must be between 1 and 65535")
+		}
+		client.port = port
+
+		return nil
+	}
+}
+
+// WithHTTPS enables HTTPS for connection to lvslb-api when endpoint is a host.
+func WithHTTPS(https bool) Option {
+	return func(client *Client) error {
+		client.https = https
+
+		return nil
+	}
+}
+
+// WithBasicAuth sets login and password for basic authentication.
+func WithBasicAuth(login, password string) Option {
+	return func(client *Client) error {
+		client.login = login
+		client.password = password
+
+		return nil
+	}
+}
+
+// WithLogname sets logname sent to lvslb-api to identify who makes the change.
+func WithLogname(logname string) Option {
+	return func(client *Client) error {
+		client.logname = logname
+
+		return nil
+	}
+}
+
+// WithRequestTags sets tags sent with each request (as X-Lvslb-Tag-<key> headers).
+func WithRequestTags(tags map[string]string) Option {
+	return func(client *Client) error {
+		client.requestTags = tags
+
+		return nil
+	}
+}
+
+// WithLogRequestBodies adds request and response bodies to debug logs.
+func WithLogRequestBodies(enable bool) Option {
+	return func(client *Client) error {
+		client.logRequestBodies = enable
+
+		return nil
+	}
+}
+
+// WithLogSecrets sets values to mask in logs (in addition to password).
+func WithLogSecrets(secrets ...string) Option {
+	return func(client *Client) error {
+		client.logSecrets = append(client.logSecrets, secrets...)
+
+		return nil
+	}
+}
+
+// WithLogger sets logger of requests sent to lvslb-api (no logs by default).
+func WithLogger(logger Logger) Option {
+	return func(client *Client) error {
+		client.logger = logger
+
+		return nil
+	}
+}
+
+// WithMaxParallelRequests limits the number of simultaneous requests (0 = unlimited).
+func WithMaxParallelRequests(maxParallelRequests int) Option {
+	return func(client *Client) error {
+		if maxParallelRequests < 0 {
+			return errors.New("max parallel requests can't be negative")
+		}
+		client.maxParallelRequests = maxParallelRequests
+
+		return nil
+	}
+}
+
+// WithRetry sets retries of requests on network errors, 429 and 5xx status code
+// with exponential backoff between waitMin and waitMax (waitMax = 0 for no maximum).
+func WithRetry(maxRetries int, waitMin, waitMax time.Duration) Option {
+	return func(client *Client) error {
+		if maxRetries < 0 {
+			return errors.New("max retries can't be negative")
+		}
+		if waitMax > 0 && waitMin > waitMax {
+			return errors.New("min wait of retry can't be greater than max wait")
+		}
+		client.maxRetries = maxRetries
+		client.retryWaitMin = waitMin
+		client.retryWaitMax = waitMax
+
+		return nil
+	}
+}
+
+// WithTLSConfig sets TLS configuration of http.Client created by New.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(client *Client) error {
+		client.tlsConfig = tlsConfig
+
+		return nil
+	}
+}
+
+// WithTimeouts sets timeouts of http.Client created by New.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(client *Client) error {
+		client.timeouts = timeouts
+
+		return nil
+	}
+}
+
+// WithHTTPClient sets http.Client used for requests
+// (WithTLSConfig, WithTimeouts and WithMaxParallelRequests don't change it).
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(client *Client) error {
+		client.httpClient = httpClient
+
+		return nil
+	}
+}
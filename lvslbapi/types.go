@@ -0,0 +1,409 @@
+package lvslbapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Protocol of virtual server.
+type Protocol string
+
+// Protocols supported by lvslb-api.
+const (
+	ProtocolTCP  Protocol = "TCP"
+	ProtocolUDP  Protocol = "UDP"
+	ProtocolSCTP Protocol = "SCTP"
+)
+
+// LbKind is the forwarding method of virtual server.
+type LbKind string
+
+// Forwarding methods supported by keepalived.
+const (
+	LbKindNAT LbKind = "NAT"
+	LbKindDR  LbKind = "DR"
+	LbKindTUN LbKind = "TUN"
+)
+
+// LbAlgo is the scheduling algorithm of virtual server.
+type LbAlgo string
+
+// Scheduling algorithms supported by keepalived.
+const (
+	LbAlgoWLC  LbAlgo = "wlc"
+	LbAlgoLC   LbAlgo = "lc"
+	LbAlgoRR   LbAlgo = "rr"
+	LbAlgoWRR  LbAlgo = "wrr"
+	LbAlgoLBLC LbAlgo = "lblc"
+	LbAlgoSH   LbAlgo = "sh"
+	LbAlgoDH   LbAlgo = "dh"
+)
+
+// CheckType is the health check of backend.
+type CheckType string
+
+// Health checks supported by keepalived.
+const (
+	CheckTypeTCP  CheckType = "TCP_CHECK"
+	CheckTypeHTTP CheckType = "HTTP_GET"
+	CheckTypeSSL  CheckType = "SSL_GET"
+	CheckTypeMisc CheckType = "MISC_CHECK"
+	CheckTypeNone CheckType = "NONE"
+)
+
+// VirtualServerKey identifies a virtual server.
+type VirtualServerKey struct {
+	IP       string
+	Protocol Protocol
+	Port     int
+}
+
+// String returns key in format <ip>_<PROTO>_<port> (also used as ID by the Terraform provider).
+func (k VirtualServerKey) String() string {
+	return k.IP + "_" + string(k.Protocol) + "_" + strconv.Itoa(k.Port)
+}
+
+// normalize writes IP in canonical form and protocol in upper case.
+func (k VirtualServerKey) normalize() VirtualServerKey {
+	k.IP = canonicalIP(k.IP)
+	k.Protocol = Protocol(strings.ToUpper(string(k.Protocol)))
+
+	return k
+}
+
+// VirtualServer is the configuration of a virtual server.
+type VirtualServer struct {
+	IP                 string
+	Port               int
+	Protocol           Protocol
+	DelayLoop          int
+	LbAlgo             LbAlgo
+	LbKind             LbKind
+	PersistenceTimeout int
+	SorryIP            string
+	SorryPort          int
+	Virtualhost        string
+	// MonPeriod is the monitoring period (not part of keepalived configuration, can be empty on read).
+	MonPeriod string
+	Backends  []Backend
+}
+
+// Key returns the key of virtual server.
+func (vs *VirtualServer) Key() VirtualServerKey {
+	return VirtualServerKey{IP: vs.IP, Protocol: vs.Protocol, Port: vs.Port}
+}
+
+// Equal reports whether vs and other have the same keepalived configuration
+// (notation of IPs, case of enums and order of backends are ignored, MonPeriod isn't compared).
+func (vs *VirtualServer) Equal(other *VirtualServer) bool {
+	if vs == nil || other == nil {
+		return vs == other
+	}
+	a, b := vs.normalize(), other.normalize()
+
+	return reflect.DeepEqual(a, b)
+}
+
+// normalize returns copy of vs in canonical form for comparison.
+func (vs *VirtualServer) normalize() VirtualServer {
+	norm := *vs
+	key := vs.Key().normalize()
+	norm.IP, norm.Protocol = key.IP, key.Protocol
+	norm.SorryIP = canonicalIP(vs.SorryIP)
+	norm.LbAlgo = LbAlgo(strings.ToLower(string(vs.LbAlgo)))
+	norm.LbKind = LbKind(strings.ToUpper(string(vs.LbKind)))
+	norm.MonPeriod = ""
+	norm.Backends = make([]Backend, 0, len(vs.Backends))
+	for _, v := range vs.Backends {
+		v.IP = canonicalIP(v.IP)
+		v.CheckType = CheckType(strings.ToUpper(string(v.CheckType)))
+		norm.Backends = append(norm.Backends, v)
+	}
+	sort.Slice(norm.Backends, func(i, j int) bool {
+		if norm.Backends[i].IP != norm.Backends[j].IP {
+			return norm.Backends[i].IP < norm.Backends[j].IP
+		}
+
+		return norm.Backends[i].Port < norm.Backends[j].Port
+	})
+
+	return norm
+}
+
+func canonicalIP(value string) string {
+	if ip := net.ParseIP(value); ip != nil {
+		return ip.String()
+	}
+
+	return value
+}
+
+// Backend is a real server of virtual server.
+type Backend struct {
+	IP               string
+	Port             int
+	Weight           int
+	CheckType        CheckType
+	CheckPort        int
+	CheckTimeout     int
+	NbGetRetry       int
+	DelayBeforeRetry int
+	URLPath          string
+	URLDigest        string
+	// URLStatusCode is the expected status code for HTTP_GET and SSL_GET (0 = not set).
+	URLStatusCode int
+	MiscPath      string
+}
+
+// VirtualServerStatus is the runtime state of backends of a virtual server.
+type VirtualServerStatus struct {
+	IP       string
+	Port     int
+	Protocol Protocol
+	Backends []BackendStatus
+}
+
+// BackendStatus is the runtime state of a backend.
+type BackendStatus struct {
+	IP           string
+	Port         int
+	Alive        bool
+	Weight       int
+	ActiveConn   int
+	InactiveConn int
+}
+
+// MarshalJSON encodes virtual server in JSON payload of lvslb-api (all values are strings).
+func (vs VirtualServer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(vs.toWire())
+}
+
+// UnmarshalJSON decodes virtual server from JSON payload of lvslb-api.
+func (vs *VirtualServer) UnmarshalJSON(data []byte) error {
+	var wire wireIpvs
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	vsWire, err := wire.virtualServer()
+	if err != nil {
+		return err
+	}
+	*vs = vsWire
+
+	return nil
+}
+
+// wire* structs are the JSON payloads of lvslb-api (all values are strings).
+type wireIpvs struct {
+	IP                 string        `json:"IP"`
+	Port               string        `json:"Port"`
+	Protocol           string        `json:"Protocol"`
+	DelayLoop          string        `json:"Delay_loop"`
+	LbAlgo             string        `json:"Lb_algo"`
+	LbKind             string        `json:"Lb_kind"`
+	PersistenceTimeout string        `json:"Persistence_timeout"`
+	SorryIP            string        `json:"Sorry_IP"`
+	SorryPort          string        `json:"Sorry_port"`
+	Backends           []wireBackend `json:"Backends"`
+	Virtualhost        string        `json:"Virtualhost"`
+	MonPeriod          string        `json:"Mon_period"`
+}
+
+type wireBackend struct {
+	IP               string `json:"IP"`
+	Port             string `json:"Port"`
+	Weight           string `json:"Weight"`
+	CheckType        string `json:"Check_type"`
+	CheckPort        string `json:"Check_port"`
+	CheckTimeout     string `json:"Check_timeout"`
+	NbGetRetry       string `json:"Nb_get_retry"`
+	DelayBeforeRetry string `json:"Delay_before_retry"`
+	URLPath          string `json:"Url_path"`
+	URLDigest        string `json:"Url_digest"`
+	URLStatusCode    string `json:"Url_status_code"`
+	MiscPath         string `json:"Misc_path"`
+}
+
+type wireStatus struct {
+	IP       string              `json:"IP"`
+	Port     string              `json:"Port"`
+	Protocol string              `json:"Protocol"`
+	Backends []wireBackendStatus `json:"Backends"`
+}
+
+type wireBackendStatus struct {
+	IP           string `json:"IP"`
+	Port         string `json:"Port"`
+	Alive        string `json:"Alive"`
+	Weight       string `json:"Weight"`
+	ActiveConn   string `json:"Active_conn"`
+	InactiveConn string `json:"Inactive_conn"`
+}
+
+func wireIpvsKey(key VirtualServerKey) wireIpvs {
+	return wireIpvs{
+		IP:       key.IP,
+		Port:     strconv.Itoa(key.Port),
+		Protocol: strings.ToUpper(string(key.Protocol)),
+	}
+}
+
+func (vs *VirtualServer) toWire() wireIpvs {
+	wire := wireIpvsKey(vs.Key())
+	wire.DelayLoop = strconv.Itoa(vs.DelayLoop)
+	wire.LbAlgo = strings.ToLower(string(vs.LbAlgo))
+	wire.LbKind = strings.ToUpper(string(vs.LbKind))
+	wire.PersistenceTimeout = strconv.Itoa(vs.PersistenceTimeout)
+	wire.SorryIP = vs.SorryIP
+	wire.SorryPort = strconv.Itoa(vs.SorryPort)
+	wire.Virtualhost = vs.Virtualhost
+	wire.MonPeriod = vs.MonPeriod
+	for _, v := range vs.Backends {
+		wire.Backends = append(wire.Backends, v.toWire())
+	}
+
+	return wire
+}
+
+func (b *Backend) toWire() wireBackend {
+	var statusCode string
+	if b.URLStatusCode != 0 {
+		statusCode = strconv.Itoa(b.URLStatusCode)
+	}
+
+	return wireBackend{
+		IP:               b.IP,
+		Port:             strconv.Itoa(b.Port),
+		Weight:           strconv.Itoa(b.Weight),
+		CheckType:        strings.ToUpper(string(b.CheckType)),
+		CheckPort:        strconv.Itoa(b.CheckPort),
+		CheckTimeout:     strconv.Itoa(b.CheckTimeout),
+		NbGetRetry:       strconv.Itoa(b.NbGetRetry),
+		DelayBeforeRetry: strconv.Itoa(b.DelayBeforeRetry),
+		URLPath:          b.URLPath,
+		URLDigest:        b.URLDigest,
+		URLStatusCode:    statusCode,
+		MiscPath:         b.MiscPath,
+	}
+}
+
+func (w *wireIpvs) virtualServer() (VirtualServer, error) {
+	var err error
+	vs := VirtualServer{
+		IP:          w.IP,
+		Protocol:    Protocol(w.Protocol),
+		LbAlgo:      LbAlgo(w.LbAlgo),
+		LbKind:      LbKind(w.LbKind),
+		SorryIP:     w.SorryIP,
+		Virtualhost: w.Virtualhost,
+		MonPeriod:   w.MonPeriod,
+		Backends:    make([]Backend, 0, len(w.Backends)),
+	}
+	if vs.Port, err = atoiAPI("Port", w.Port); err != nil {
+		return vs, err
+	}
+	if vs.DelayLoop, err = atoiAPI("Delay_loop", w.DelayLoop); err != nil {
+		return vs, err
+	}
+	if vs.PersistenceTimeout, err = atoiAPI("Persistence_timeout", w.PersistenceTimeout); err != nil {
+		return vs, err
+	}
+	if vs.SorryPort, err = atoiAPI("Sorry_port", w.SorryPort); err != nil {
+		return vs, err
+	}
+	for _, v := range w.Backends {
+		backend, err := v.backend()
+		if err != nil {
+			return vs, err
+		}
+		vs.Backends = append(vs.Backends, backend)
+	}
+
+	return vs, nil
+}
+
+func (w *wireBackend) backend() (Backend, error) {
+	var err error
+	backend := Backend{
+		IP:        w.IP,
+		CheckType: CheckType(w.CheckType),
+		URLPath:   w.URLPath,
+		URLDigest: w.URLDigest,
+		MiscPath:  w.MiscPath,
+	}
+	if backend.Port, err = atoiAPI("Port", w.Port); err != nil {
+		return backend, err
+	}
+	if backend.Weight, err = atoiAPI("Weight", w.Weight); err != nil {
+		return backend, err
+	}
+	if backend.CheckPort, err = atoiAPI("Check_port", w.CheckPort); err != nil {
+		return backend, err
+	}
+	if backend.CheckTimeout, err = atoiAPI("Check_timeout", w.CheckTimeout); err != nil {
+		return backend, err
+	}
+	if backend.NbGetRetry, err = atoiAPI("Nb_get_retry", w.NbGetRetry); err != nil {
+		return backend, err
+	}
+	if backend.DelayBeforeRetry, err = atoiAPI("Delay_before_retry", w.DelayBeforeRetry); err != nil {
+		return backend, err
+	}
+	if backend.URLStatusCode, err = atoiAPI("Url_status_code", w.URLStatusCode); err != nil {
+		return backend, err
+	}
+
+	return backend, nil
+}
+
+func (w *wireStatus) virtualServerStatus() (VirtualServerStatus, error) {
+	var err error
+	status := VirtualServerStatus{
+		IP:       w.IP,
+		Protocol: Protocol(w.Protocol),
+		Backends: make([]BackendStatus, 0, len(w.Backends)),
+	}
+	if status.Port, err = atoiAPI("Port", w.Port); err != nil {
+		return status, err
+	}
+	for _, v := range w.Backends {
+		backend := BackendStatus{IP: v.IP}
+		if v.Alive != "" {
+			if backend.Alive, err = strconv.ParseBool(v.Alive); err != nil {
+				return status, fmt.Errorf("convert Alive in API response (%v) %v", err, v.Alive)
+			}
+		}
+		if backend.Port, err = atoiAPI("Port", v.Port); err != nil {
+			return status, err
+		}
+		if backend.Weight, err = atoiAPI("Weight", v.Weight); err != nil {
+			return status, err
+		}
+		if backend.ActiveConn, err = atoiAPI("Active_conn", v.ActiveConn); err != nil {
+			return status, err
+		}
+		if backend.InactiveConn, err = atoiAPI("Inactive_conn", v.InactiveConn); err != nil {
+			return status, err
+		}
+		status.Backends = append(status.Backends, backend)
+	}
+
+	return status, nil
+}
+
+func atoiAPI(field, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("convert %s in API response (%v) %v", field, err, value)
+	}
+
+	return i, nil
+}